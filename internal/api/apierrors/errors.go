@@ -3,10 +3,16 @@ package apierrors
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 var ErrInvalidCredentials = errors.New("invalid username or password")
 var ErrIsUnauthorized = errors.New("user is unauthorized to edit the asset")
+var ErrInvalidToken = errors.New("token is invalid, expired or was already used")
+var ErrEmailNotVerified = errors.New("email address of the user is not verified")
+var ErrUserSuspended = errors.New("user account is suspended")
 
 // USED FOR /get METHODS WITH QUERY PARAMS - ALL INVALID PARAMETER ERRORS FALL INTO ErrInvalidSpotParameters
 var ErrInvalidQueryParameters = fmt.Errorf("invalid query parameters")
@@ -24,3 +30,63 @@ func (e *InvalidQueryParameterError) Error() string {
 func (e *InvalidQueryParameterError) Unwrap() error {
 	return ErrInvalidQueryParameters
 }
+
+// Returned when the request body passed the validation, but some fields were rejected by the service,
+// e.g. a password not meeting the policy. Fields maps the JSON field names to the reasons.
+type InvalidFieldsError struct {
+	Fields map[string][]string
+}
+
+func (e *InvalidFieldsError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return "invalid fields: " + strings.Join(names, ", ")
+}
+
+// Returned when the new spot looks like one of the existing ones, unless the user confirms it is different.
+type DuplicateSpotError struct {
+	CandidateIds []string
+}
+
+func (e *DuplicateSpotError) Error() string {
+	return fmt.Sprintf("spot looks like a duplicate of %d existing spot(s)", len(e.CandidateIds))
+}
+
+// Returned when the requested resource was moved, e.g. the spot was merged into another one.
+type MovedPermanentlyError struct {
+	Location string
+}
+
+func (e *MovedPermanentlyError) Error() string {
+	return "resource moved permanently to " + e.Location
+}
+
+// Returned on login when the password is correct, but the user has two-factor authentication enabled.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication code is required"
+}
+
+// Returned when the login, or another limited action, is temporarily locked after too many attempts.
+type TooManyRequestsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("too many attempts, try again in %d seconds", retryAfterSeconds(e.RetryAfter))
+}
+
+func (e *TooManyRequestsError) RetryAfterSeconds() int {
+	return retryAfterSeconds(e.RetryAfter)
+}
+
+func retryAfterSeconds(retryAfter time.Duration) int {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	return max(seconds, 1)
+}
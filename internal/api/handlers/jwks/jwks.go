@@ -0,0 +1,18 @@
+package jwks
+
+import (
+	"net/http"
+	"scenic-spots-api/internal/api/helpers"
+	"scenic-spots-api/internal/auth"
+)
+
+// Publishes the public keys, so other services can verify the tokens without sharing a secret.
+func Jwks(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	response.Header().Set("Cache-Control", "public, max-age=3600")
+	helpers.WriteJSONResponse(response, http.StatusOK, auth.PublicKeys())
+}
@@ -1,13 +1,22 @@
 package review
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	helpers "scenic-spots-api/internal/api/helpers"
 	reviewService "scenic-spots-api/internal/api/service/review"
 	"scenic-spots-api/internal/models"
+	"slices"
+	"strconv"
 	"strings"
 )
 
+const maxReviewPhotos = 5
+const maxPhotoSize = 5 << 20
+
+var allowedPhotoTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
 func Review(response http.ResponseWriter, request *http.Request, spotId string) {
 	parts := strings.Split(request.URL.Path, "/")
 	numberOfParts := len(parts)
@@ -56,11 +65,69 @@ func Review(response http.ResponseWriter, request *http.Request, spotId string)
 		default:
 			response.WriteHeader(http.StatusMethodNotAllowed)
 		}
+	} else if numberOfParts == 6 {
+		reviewId := parts[4]
+		if reviewId == "" {
+			helpers.ErrorResponse(response, "Missing review ID", http.StatusBadRequest)
+			return
+		}
+		switch parts[5] {
+		case "vote":
+			ReviewVote(response, request, spotId, reviewId)
+		case "reply":
+			ReviewReply(response, request, spotId, reviewId)
+		case "history":
+			if method != "GET" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			getReviewHistory(response, request, spotId, reviewId)
+		default:
+			response.WriteHeader(http.StatusNotFound)
+		}
 	} else {
 		response.WriteHeader(http.StatusNotFound)
 	}
 }
 
+func ReviewVote(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	method := request.Method
+
+	if err := helpers.IsAuthenticated(request); err != nil {
+		helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch method {
+	case "POST":
+		voteOnReview(response, request, spotId, reviewId)
+	case "DELETE":
+		deleteVote(response, request, spotId, reviewId)
+	default:
+		response.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func ReviewReply(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	method := request.Method
+
+	if err := helpers.IsAuthenticated(request); err != nil {
+		helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch method {
+	case "POST":
+		addReply(response, request, spotId, reviewId)
+	case "PATCH":
+		updateReply(response, request, spotId, reviewId)
+	case "DELETE":
+		deleteReply(response, request, spotId, reviewId)
+	default:
+		response.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func getReview(response http.ResponseWriter, request *http.Request, spotId string) {
 	if !helpers.RequestBodyIsEmpty(request) {
 		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
@@ -77,20 +144,26 @@ func getReview(response http.ResponseWriter, request *http.Request, spotId strin
 }
 
 func addReview(response http.ResponseWriter, request *http.Request, spotId string) {
-	token, err := helpers.GetJWTToken(request)
+	var err error
+	var newReview models.NewReview
+	var uploads []models.PhotoUpload
+	newReview.SpotId = spotId
+	if helpers.IsMultipartRequest(request) {
+		uploads, err = decodeMultipartReview(request, &newReview)
+	} else {
+		err = helpers.DecodeAndValidateRequestBody(request, &newReview)
+	}
 	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var newReview models.NewReview
-	newReview.SpotId = spotId
-	if err := helpers.DecodeAndValidateRequestBody(request, &newReview); err != nil {
-		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+	if len(newReview.PhotoIds)+len(uploads) > maxReviewPhotos {
+		helpers.ErrorResponse(response, "Error while decoding request body: too many photos", http.StatusBadRequest)
 		return
 	}
 
-	found, err := reviewService.AddReview(request.Context(), token, newReview)
+	found, err := reviewService.AddReview(request.Context(), newReview, uploads)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -99,6 +172,54 @@ func addReview(response http.ResponseWriter, request *http.Request, spotId strin
 	helpers.WriteJSONResponse(response, http.StatusOK, found)
 }
 
+// Multipart review consists of rating, content, photoIds and addToSpot fields, and photos files.
+func decodeMultipartReview(request *http.Request, newReview *models.NewReview) ([]models.PhotoUpload, error) {
+	if err := request.ParseMultipartForm(maxReviewPhotos * maxPhotoSize); err != nil {
+		return nil, fmt.Errorf("Bad request body")
+	}
+
+	rating, err := strconv.ParseFloat(request.FormValue("rating"), 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid parameters")
+	}
+	newReview.Rating = float32(rating)
+	newReview.Content = request.FormValue("content")
+	newReview.PhotoIds = request.MultipartForm.Value["photoIds"]
+	newReview.AddToSpot = request.FormValue("addToSpot") == "true"
+
+	if err := helpers.ValidateRequestStruct(newReview); err != nil {
+		return nil, err
+	}
+
+	uploads := make([]models.PhotoUpload, 0)
+	for _, fileHeader := range request.MultipartForm.File["photos"] {
+		contentType := fileHeader.Header.Get("Content-Type")
+		if !slices.Contains(allowedPhotoTypes, contentType) {
+			return nil, fmt.Errorf("unsupported photo type %v", contentType)
+		}
+		if fileHeader.Size > maxPhotoSize {
+			return nil, fmt.Errorf("photo %v is too large", fileHeader.Filename)
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, models.PhotoUpload{
+			ContentType: contentType,
+			Data:        data,
+		})
+	}
+
+	return uploads, nil
+}
+
 func getReviewById(response http.ResponseWriter, request *http.Request, id string) {
 	if !helpers.RequestBodyIsEmpty(request) {
 		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
@@ -114,20 +235,29 @@ func getReviewById(response http.ResponseWriter, request *http.Request, id strin
 	helpers.WriteJSONResponse(response, http.StatusOK, review)
 }
 
-func updateReviewById(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
-	token, err := helpers.GetJWTToken(request)
+func getReviewHistory(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	history, err := reviewService.GetReviewHistory(request.Context(), spotId, reviewId)
 	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
+		helpers.HandleErrors(response, err)
 		return
 	}
 
+	helpers.WriteJSONResponse(response, http.StatusOK, history)
+}
+
+func updateReviewById(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
 	var reviewInfo models.ReviewInfo
 	if err := helpers.DecodeAndValidateRequestBody(request, &reviewInfo); err != nil {
 		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	updatedReview, err := reviewService.UpdateReviewById(request.Context(), token, reviewInfo, reviewId)
+	updatedReview, err := reviewService.UpdateReviewById(request.Context(), reviewInfo, reviewId)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -141,13 +271,8 @@ func deleteReviewById(response http.ResponseWriter, request *http.Request, id st
 		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
 		return
 	}
-	token, err := helpers.GetJWTToken(request)
-	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
-		return
-	}
 
-	err = reviewService.DeleteReviewById(request.Context(), token, id)
+	err := reviewService.DeleteReviewById(request.Context(), id)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -161,14 +286,83 @@ func deleteAllReviews(response http.ResponseWriter, request *http.Request, spotI
 		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
 		return
 	}
-	token, err := helpers.GetJWTToken(request)
+	if err := reviewService.DeleteAllReviews(request.Context(), spotId); err != nil {
+		helpers.ErrorResponse(response, "Unexpected error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func voteOnReview(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	var voteInfo models.ReviewVoteInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &voteInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review, err := reviewService.VoteOnReview(request.Context(), spotId, reviewId, voteInfo)
 	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
+		helpers.HandleErrors(response, err)
 		return
 	}
 
-	if err := reviewService.DeleteAllReviews(request.Context(), token, spotId); err != nil {
-		helpers.ErrorResponse(response, "Unexpected error: "+err.Error(), http.StatusInternalServerError)
+	helpers.WriteJSONResponse(response, http.StatusOK, review)
+}
+
+func deleteVote(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
+		return
+	}
+	review, err := reviewService.DeleteVote(request.Context(), spotId, reviewId)
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, review)
+}
+
+func addReply(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	var replyInfo models.ReviewReplyInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &replyInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review, err := reviewService.AddReply(request.Context(), spotId, reviewId, replyInfo)
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, review)
+}
+
+func updateReply(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	var replyInfo models.ReviewReplyInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &replyInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review, err := reviewService.UpdateReply(request.Context(), spotId, reviewId, replyInfo)
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, review)
+}
+
+func deleteReply(response http.ResponseWriter, request *http.Request, spotId string, reviewId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
+		return
+	}
+	if err := reviewService.DeleteReply(request.Context(), spotId, reviewId); err != nil {
+		helpers.HandleErrors(response, err)
 		return
 	}
 
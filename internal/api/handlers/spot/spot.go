@@ -37,6 +37,19 @@ func SpotById(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
+	if numberOfParts == 3 && (spotId == "search" || spotId == "suggest") {
+		if method != "GET" {
+			response.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if spotId == "search" {
+			searchSpots(response, request)
+		} else {
+			suggestSpots(response, request)
+		}
+		return
+	}
+
 	if numberOfParts == 3 {
 		switch method {
 		case "GET":
@@ -59,10 +72,34 @@ func SpotById(response http.ResponseWriter, request *http.Request) {
 	} else if numberOfParts >= 4 {
 		spotElement := parts[3]
 		switch spotElement {
-		case "photo":
-			pHandler.Photo(response, request, spotId)
-		case "review":
-			rHandler.Review(response, request, spotId)
+		case "merge":
+			if numberOfParts != 4 {
+				response.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if err := helpers.IsAuthenticated(request); err != nil {
+				helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			mergeSpot(response, request, spotId)
+		case "photo", "review":
+			path := "/" + strings.Join(parts[3:], "/")
+			if request.URL.RawQuery != "" {
+				path += "?" + request.URL.RawQuery
+			}
+			if err := spotService.EnsureIsNotMerged(request.Context(), spotId, path); err != nil {
+				helpers.HandleErrors(response, err)
+				return
+			}
+			if spotElement == "photo" {
+				pHandler.Photo(response, request, spotId)
+			} else {
+				rHandler.Review(response, request, spotId)
+			}
 		default:
 			response.WriteHeader(http.StatusNotFound)
 		}
@@ -85,20 +122,42 @@ func getSpot(response http.ResponseWriter, request *http.Request) {
 	helpers.WriteJSONResponse(response, http.StatusOK, found)
 }
 
-func addSpot(response http.ResponseWriter, request *http.Request) {
-	token, err := helpers.GetJWTToken(request)
+func searchSpots(response http.ResponseWriter, request *http.Request) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	found, err := spotService.SearchSpots(request.Context(), request.URL.Query())
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+	helpers.WriteJSONResponse(response, http.StatusOK, found)
+}
+
+func suggestSpots(response http.ResponseWriter, request *http.Request) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	found, err := spotService.SuggestSpots(request.Context(), request.URL.Query())
 	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
+		helpers.HandleErrors(response, err)
 		return
 	}
+	helpers.WriteJSONResponse(response, http.StatusOK, found)
+}
 
+func addSpot(response http.ResponseWriter, request *http.Request) {
 	var spot models.NewSpot
 	if err := helpers.DecodeAndValidateRequestBody(request, &spot); err != nil {
 		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	result, err := spotService.AddSpot(request.Context(), token, spot)
+	result, err := spotService.AddSpot(request.Context(), spot)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -123,19 +182,13 @@ func getSpotById(response http.ResponseWriter, request *http.Request, id string)
 }
 
 func updateSpotById(response http.ResponseWriter, request *http.Request, id string) {
-	token, err := helpers.GetJWTToken(request)
-	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
 	var spot models.NewSpot
 	if err := helpers.DecodeAndValidateRequestBody(request, &spot); err != nil {
 		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	result, err := spotService.UpdateSpotById(request.Context(), token, spot, id)
+	result, err := spotService.UpdateSpotById(request.Context(), spot, id)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -149,17 +202,28 @@ func deleteSpotById(response http.ResponseWriter, request *http.Request, id stri
 		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
 		return
 	}
-	token, err := helpers.GetJWTToken(request)
+
+	err := spotService.DeleteSpotById(request.Context(), id)
 	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func mergeSpot(response http.ResponseWriter, request *http.Request, id string) {
+	var mergeInfo models.SpotMergeInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &mergeInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = spotService.DeleteSpotById(request.Context(), token, id)
+	result, err := spotService.MergeSpot(request.Context(), id, mergeInfo)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
 	}
 
-	response.WriteHeader(http.StatusNoContent)
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
 }
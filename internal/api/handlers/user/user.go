@@ -9,6 +9,19 @@ import (
 	"strings"
 )
 
+// Lists the users, served at /user and /user/.
+func Users(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "GET" {
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := helpers.IsAuthenticated(request); err != nil {
+		helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	getUsers(response, request)
+}
+
 func User(response http.ResponseWriter, request *http.Request) {
 	parts := strings.Split(request.URL.Path, "/")
 	numberOfParts := len(parts)
@@ -30,9 +43,139 @@ func User(response http.ResponseWriter, request *http.Request) {
 				return
 			}
 			loginUser(response, request)
+
+		case "logout":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if err := helpers.IsAuthenticated(request); err != nil {
+				helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			logoutUser(response, request)
+
+		case "verify":
+			if method != "GET" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			verifyEmail(response, request)
+
+		case "":
+			Users(response, request)
 		default:
 			UserById(response, request, operation)
 		}
+	} else if numberOfParts == 4 {
+		operation := parts[2] + "/" + parts[3]
+		switch operation {
+		case "token/refresh":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			refreshToken(response, request)
+
+		case "me/sessions":
+			if method != "GET" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if err := helpers.IsAuthenticated(request); err != nil {
+				helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			getSessions(response, request)
+
+		case "me/password":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if err := helpers.IsAuthenticated(request); err != nil {
+				helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			setUserPassword(response, request)
+
+		case "login/magic":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			sendMagicLink(response, request)
+
+		case "login/2fa":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			completeTwoFactorLogin(response, request)
+
+		case "me/verify":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if err := helpers.IsAuthenticated(request); err != nil {
+				helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			resendVerificationEmail(response, request)
+
+		case "password/forgot":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			forgotPassword(response, request)
+
+		case "password/reset":
+			if method != "POST" {
+				response.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			resetPassword(response, request)
+
+		default:
+			userAction(response, request, parts[2], parts[3])
+		}
+	} else if numberOfParts == 5 && parts[2] == "login" && parts[3] == "magic" && parts[4] == "verify" {
+		if method != "POST" {
+			response.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		loginWithMagicLink(response, request)
+	} else if numberOfParts == 5 && parts[2] == "me" && parts[3] == "2fa" {
+		if method != "POST" {
+			response.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := helpers.IsAuthenticated(request); err != nil {
+			helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		switch parts[4] {
+		case "setup":
+			setupTwoFactor(response, request)
+		case "enable":
+			enableTwoFactor(response, request)
+		case "disable":
+			disableTwoFactor(response, request)
+		default:
+			response.WriteHeader(http.StatusNotFound)
+		}
+	} else if numberOfParts == 5 && parts[2] == "me" && parts[3] == "sessions" {
+		if method != "DELETE" {
+			response.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if err := helpers.IsAuthenticated(request); err != nil {
+			helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		deleteSession(response, request, parts[4])
 	} else {
 		response.WriteHeader(http.StatusNotFound)
 	}
@@ -42,6 +185,12 @@ func UserById(response http.ResponseWriter, request *http.Request, id string) {
 	method := request.Method
 
 	switch method {
+	case "GET":
+		if err := helpers.IsAuthenticated(request); err != nil {
+			helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		getUserById(response, request, id)
 	case "DELETE":
 		if err := helpers.IsAuthenticated(request); err != nil {
 			helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
@@ -53,6 +202,37 @@ func UserById(response http.ResponseWriter, request *http.Request, id string) {
 	}
 }
 
+// Handles the /user/{id}/{action} endpoints, used for managing the user.
+func userAction(response http.ResponseWriter, request *http.Request, id string, action string) {
+	method := request.Method
+
+	var handler func(http.ResponseWriter, *http.Request, string)
+	switch {
+	case action == "sessions" && method == "DELETE":
+		handler = revokeUserSessions
+	case action == "lockout" && method == "DELETE":
+		handler = unlockUser
+	case action == "role" && method == "PATCH":
+		handler = changeUserRole
+	case action == "suspension" && method == "POST":
+		handler = suspendUser
+	case action == "suspension" && method == "DELETE":
+		handler = unsuspendUser
+	case action == "sessions" || action == "lockout" || action == "role" || action == "suspension":
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	default:
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := helpers.IsAuthenticated(request); err != nil {
+		helpers.ErrorResponse(response, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	handler(response, request, id)
+}
+
 func registerUser(response http.ResponseWriter, request *http.Request) {
 	var userRegisterInfo models.UserRegisterInfo
 	if err := helpers.DecodeAndValidateRequestBody(request, &userRegisterInfo); err != nil {
@@ -60,7 +240,7 @@ func registerUser(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	result, err := userService.RegisterUser(request.Context(), userRegisterInfo)
+	result, err := userService.RegisterUser(request.Context(), userRegisterInfo, helpers.GetClientInfo(request, ""))
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -76,7 +256,7 @@ func loginUser(response http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	result, err := userService.LoginUser(request.Context(), userCredentials)
+	result, err := userService.LoginUser(request.Context(), userCredentials, helpers.GetClientInfo(request, userCredentials.DeviceLabel))
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -85,18 +265,324 @@ func loginUser(response http.ResponseWriter, request *http.Request) {
 	helpers.WriteJSONResponse(response, http.StatusOK, result)
 }
 
-func deleteUserById(response http.ResponseWriter, request *http.Request, userId string) {
+func refreshToken(response http.ResponseWriter, request *http.Request) {
+	var refreshTokenRequest models.RefreshTokenRequest
+	if err := helpers.DecodeAndValidateRequestBody(request, &refreshTokenRequest); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.RefreshToken(request.Context(), refreshTokenRequest, helpers.GetClientInfo(request, ""))
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func logoutUser(response http.ResponseWriter, request *http.Request) {
+	if err := userService.Logout(request.Context()); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func getSessions(response http.ResponseWriter, request *http.Request) {
+	result, err := userService.GetSessions(request.Context())
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func deleteSession(response http.ResponseWriter, request *http.Request, sessionId string) {
 	if !helpers.RequestBodyIsEmpty(request) {
 		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
 		return
 	}
-	token, err := helpers.GetJWTToken(request)
+
+	if err := userService.DeleteSession(request.Context(), sessionId); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func revokeUserSessions(response http.ResponseWriter, request *http.Request, userId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.RevokeUserSessions(request.Context(), userId); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func setUserPassword(response http.ResponseWriter, request *http.Request) {
+	var passwordChangeInfo models.PasswordChangeInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &passwordChangeInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.ChangePassword(request.Context(), passwordChangeInfo, helpers.GetClientInfo(request, ""))
 	if err != nil {
-		helpers.ErrorResponse(response, "Error while decoding header: "+err.Error(), http.StatusBadRequest)
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func forgotPassword(response http.ResponseWriter, request *http.Request) {
+	var passwordForgotInfo models.PasswordForgotInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &passwordForgotInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = userService.DeleteUserById(request.Context(), token, userId)
+	if err := userService.ForgotPassword(request.Context(), passwordForgotInfo, helpers.GetClientInfo(request, "")); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func resetPassword(response http.ResponseWriter, request *http.Request) {
+	var passwordResetInfo models.PasswordResetInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &passwordResetInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.ResetPassword(request.Context(), passwordResetInfo); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func sendMagicLink(response http.ResponseWriter, request *http.Request) {
+	var magicLinkRequest models.MagicLinkRequest
+	if err := helpers.DecodeAndValidateRequestBody(request, &magicLinkRequest); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.SendMagicLink(request.Context(), magicLinkRequest, helpers.GetClientInfo(request, "")); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func loginWithMagicLink(response http.ResponseWriter, request *http.Request) {
+	var magicLinkLogin models.MagicLinkLogin
+	if err := helpers.DecodeAndValidateRequestBody(request, &magicLinkLogin); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.LoginWithMagicLink(request.Context(), magicLinkLogin, helpers.GetClientInfo(request, magicLinkLogin.DeviceLabel))
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func completeTwoFactorLogin(response http.ResponseWriter, request *http.Request) {
+	var twoFactorLoginInfo models.TwoFactorLoginInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &twoFactorLoginInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.CompleteTwoFactorLogin(request.Context(), twoFactorLoginInfo, helpers.GetClientInfo(request, twoFactorLoginInfo.DeviceLabel))
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func setupTwoFactor(response http.ResponseWriter, request *http.Request) {
+	result, err := userService.SetupTwoFactor(request.Context())
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func enableTwoFactor(response http.ResponseWriter, request *http.Request) {
+	var twoFactorCodeInfo models.TwoFactorCodeInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &twoFactorCodeInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.EnableTwoFactor(request.Context(), twoFactorCodeInfo, helpers.GetClientInfo(request, ""))
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func disableTwoFactor(response http.ResponseWriter, request *http.Request) {
+	var twoFactorDisableInfo models.TwoFactorDisableInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &twoFactorDisableInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.DisableTwoFactor(request.Context(), twoFactorDisableInfo, helpers.GetClientInfo(request, "")); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func verifyEmail(response http.ResponseWriter, request *http.Request) {
+	token := request.URL.Query().Get("token")
+	if token == "" {
+		helpers.ErrorResponse(response, "Missing token query parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.VerifyEmail(request.Context(), token); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func resendVerificationEmail(response http.ResponseWriter, request *http.Request) {
+	if err := userService.ResendVerificationEmail(request.Context(), helpers.GetClientInfo(request, "")); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func unlockUser(response http.ResponseWriter, request *http.Request, userId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.UnlockUser(request.Context(), userId); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func getUsers(response http.ResponseWriter, request *http.Request) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	found, err := userService.GetUsers(request.Context(), request.URL.Query())
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, found)
+}
+
+func getUserById(response http.ResponseWriter, request *http.Request, userId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "GET request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	found, err := userService.GetUserById(request.Context(), userId)
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, found)
+}
+
+func changeUserRole(response http.ResponseWriter, request *http.Request, userId string) {
+	var roleInfo models.UserRoleInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &roleInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.ChangeUserRole(request.Context(), userId, roleInfo)
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func suspendUser(response http.ResponseWriter, request *http.Request, userId string) {
+	var suspensionInfo models.UserSuspensionInfo
+	if err := helpers.DecodeAndValidateRequestBody(request, &suspensionInfo); err != nil {
+		helpers.ErrorResponse(response, "Error while decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := userService.SuspendUser(request.Context(), userId, suspensionInfo)
+	if err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	helpers.WriteJSONResponse(response, http.StatusOK, result)
+}
+
+func unsuspendUser(response http.ResponseWriter, request *http.Request, userId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	if err := userService.UnsuspendUser(request.Context(), userId); err != nil {
+		helpers.HandleErrors(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func deleteUserById(response http.ResponseWriter, request *http.Request, userId string) {
+	if !helpers.RequestBodyIsEmpty(request) {
+		helpers.ErrorResponse(response, "DELETE request must not contain a body", http.StatusBadRequest)
+		return
+	}
+
+	err := userService.DeleteUserById(request.Context(), userId)
 	if err != nil {
 		helpers.HandleErrors(response, err)
 		return
@@ -111,10 +597,6 @@ func getUserInfo(response http.ResponseWriter, request *http.Request) {
 	logger.Info("Fetching user profile info.")
 }
 
-func setUserPassword(response http.ResponseWriter, request *http.Request) {
-	logger.Info("Changing user password.")
-}
-
 func updateUserInfo(response http.ResponseWriter, request *http.Request) {
 	logger.Info("Updating user profile info.")
 }
@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"scenic-spots-api/internal/auth"
@@ -17,11 +18,41 @@ func GetJWTToken(request *http.Request) (string, error) {
 	return token, nil
 }
 
+type tokenErrorKey struct{}
+
+// Verifies the JWT token once per request and stores the identity in the request context.
+// Requests without the Authorization header, or with an invalid one, pass through as anonymous ones, so an
+// expired token does not block logging in, refreshing the token or the public routes. The reason the token
+// was rejected is kept for the handlers that require a principal.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") == "" {
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		token, err := GetJWTToken(request)
+		if err != nil {
+			next.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), tokenErrorKey{}, err)))
+			return
+		}
+
+		principal, err := auth.VerifyToken(request.Context(), token)
+		if err != nil {
+			next.ServeHTTP(response, request.WithContext(context.WithValue(request.Context(), tokenErrorKey{}, err)))
+			return
+		}
+
+		next.ServeHTTP(response, request.WithContext(auth.WithPrincipal(request.Context(), principal)))
+	})
+}
+
 func IsAuthenticated(request *http.Request) error {
-	token, err := GetJWTToken(request)
-	if err != nil {
-		return err
+	if _, err := auth.PrincipalFromContext(request.Context()); err != nil {
+		if tokenErr, ok := request.Context().Value(tokenErrorKey{}).(error); ok {
+			return tokenErr
+		}
+		return fmt.Errorf("missing or invalid Authorization header")
 	}
-
-	return auth.VerifyToken(token)
+	return nil
 }
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"net/http"
 	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
 	"scenic-spots-api/internal/database/repositories/repoerrors"
+	"scenic-spots-api/internal/models"
+	"strconv"
 )
 
 func WriteJSONResponse(response http.ResponseWriter, status int, data any) {
@@ -17,15 +20,53 @@ func WriteJSONResponse(response http.ResponseWriter, status int, data any) {
 }
 
 func HandleErrors(response http.ResponseWriter, err error) {
+	var duplicateErr *apierrors.DuplicateSpotError
+	var movedErr *apierrors.MovedPermanentlyError
+	var twoFactorErr *apierrors.TwoFactorRequiredError
+	var tooManyRequestsErr *apierrors.TooManyRequestsError
+	var invalidFieldsErr *apierrors.InvalidFieldsError
+
 	switch {
+	case errors.As(err, &movedErr):
+		response.Header().Set("Location", movedErr.Location)
+		ErrorResponse(response, "Moved: "+err.Error(), http.StatusMovedPermanently)
+	case errors.As(err, &tooManyRequestsErr):
+		response.Header().Set("Retry-After", strconv.Itoa(tooManyRequestsErr.RetryAfterSeconds()))
+		ErrorResponse(response, "Too many requests: "+err.Error(), http.StatusTooManyRequests)
+	case errors.As(err, &twoFactorErr):
+		WriteJSONResponse(response, http.StatusUnauthorized, models.TwoFactorRequiredAPIError{
+			Code:           http.StatusUnauthorized,
+			Message:        "Authorization error: " + err.Error(),
+			ChallengeToken: twoFactorErr.ChallengeToken,
+		})
+	case errors.As(err, &invalidFieldsErr):
+		WriteJSONResponse(response, http.StatusBadRequest, models.InvalidFieldsAPIError{
+			Code:    http.StatusBadRequest,
+			Message: "Invalid parameters: " + err.Error(),
+			Fields:  invalidFieldsErr.Fields,
+		})
+	case errors.As(err, &duplicateErr):
+		WriteJSONResponse(response, http.StatusConflict, models.DuplicateSpotAPIError{
+			Code:         http.StatusConflict,
+			Message:      "Conflict: " + err.Error(),
+			CandidateIds: duplicateErr.CandidateIds,
+		})
 	case errors.Is(err, repoerrors.ErrDoesNotExist):
 		ErrorResponse(response, "Item does not exist", http.StatusNotFound)
 	case errors.Is(err, repoerrors.ErrAlreadyExists):
 		ErrorResponse(response, "Conflict: resource already exists", http.StatusConflict)
 	case errors.Is(err, apierrors.ErrInvalidQueryParameters):
 		ErrorResponse(response, "Invalid query parameters: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, auth.ErrNoPrincipal):
+		ErrorResponse(response, "Authorization error: "+err.Error(), http.StatusUnauthorized)
 	case errors.Is(err, apierrors.ErrInvalidCredentials):
 		ErrorResponse(response, "Authorization error: "+err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, apierrors.ErrInvalidToken):
+		ErrorResponse(response, "Invalid token: "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, apierrors.ErrUserSuspended):
+		ErrorResponse(response, "Permission error: "+err.Error(), http.StatusForbidden)
+	case errors.Is(err, apierrors.ErrEmailNotVerified):
+		ErrorResponse(response, "Permission error: "+err.Error(), http.StatusForbidden)
 	case errors.Is(err, apierrors.ErrIsUnauthorized):
 		ErrorResponse(response, "Permission error: "+err.Error(), http.StatusForbidden)
 	default:
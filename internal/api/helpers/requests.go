@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"scenic-spots-api/internal/models"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -26,9 +30,96 @@ func DecodeAndValidateRequestBody[T any](request *http.Request, requestBodyStruc
 		return fmt.Errorf("Bad request body")
 	}
 
+	return ValidateRequestStruct(requestBodyStruct)
+}
+
+// Used for request bodies that are not decoded from JSON, e.g. multipart forms.
+func ValidateRequestStruct[T any](requestStruct *T) error {
 	validate := validator.New()
-	if err := validate.Struct(requestBodyStruct); err != nil {
+	if err := validate.Struct(requestStruct); err != nil {
 		return fmt.Errorf("Invalid parameters")
 	}
 	return nil
 }
+
+func IsMultipartRequest(request *http.Request) bool {
+	return strings.HasPrefix(request.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+const maxDeviceLabelLength = 100
+
+// Describes the client of the request for its session, the device label defaults to the User-Agent header.
+func GetClientInfo(request *http.Request, deviceLabel string) models.ClientInfo {
+	if deviceLabel == "" {
+		deviceLabel = request.Header.Get("User-Agent")
+		if len(deviceLabel) > maxDeviceLabelLength {
+			deviceLabel = deviceLabel[:maxDeviceLabelLength]
+		}
+	}
+
+	return models.ClientInfo{
+		DeviceLabel: deviceLabel,
+		IpAddress:   clientIp(request),
+	}
+}
+
+// Networks of the reverse proxies allowed to set the X-Forwarded-For header.
+var trustedProxies []*net.IPNet
+
+// Reads TRUSTED_PROXIES, a comma separated list of IP addresses or CIDR ranges. Without it the header is
+// ignored, as any client could set it to get around the IP lockout or to lock out someone else.
+func InitializeTrustedProxies() error {
+	trustedProxies = nil
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cidr := entry
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES contains an invalid address %q", entry)
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+	return nil
+}
+
+// The address of the connection, unless it is a trusted proxy - then the closest address in X-Forwarded-For
+// that is not a trusted proxy. Entries further left are set by the client, so they are never trusted.
+func clientIp(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+
+	forwardedFor := strings.Split(request.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwardedFor) - 1; i >= 0 && isTrustedProxy(ip); i-- {
+		forwardedIp := net.ParseIP(strings.TrimSpace(forwardedFor[i]))
+		if forwardedIp == nil {
+			break
+		}
+		ip = forwardedIp
+	}
+	return ip.String()
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,58 +3,144 @@ package review
 import (
 	"context"
 	"net/url"
+	"scenic-spots-api/internal/api/apierrors"
 	"scenic-spots-api/internal/auth"
+	photoRepo "scenic-spots-api/internal/database/repositories/photo"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
 	reviewRepo "scenic-spots-api/internal/database/repositories/review"
 	spotRepo "scenic-spots-api/internal/database/repositories/spot"
 	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/generics"
+	"scenic-spots-api/utils/logger"
+	"slices"
 	"time"
 )
 
-func GetReview(ctx context.Context, spotId string, query url.Values) ([]models.Review, error) {
+func GetReview(ctx context.Context, spotId string, query url.Values) (models.Page[models.Review], error) {
 	if _, err := spotRepo.FindSpotById(ctx, spotId); err != nil {
-		return []models.Review{}, err
+		return models.Page[models.Review]{}, err
 	}
 
 	params := models.ReviewQueryParams{
 		SpotId:  spotId,
 		Limit:   query.Get("limit"),
 		AddedBy: query.Get("addedBy"),
+		Sort:    query.Get("sort"),
+		PageParams: models.PageParams{
+			PageSize:  query.Get("pageSize"),
+			PageToken: query.Get("pageToken"),
+		},
 	}
 
 	found, err := reviewRepo.GetReviews(ctx, params)
 	if err != nil {
-		return []models.Review{}, err
+		return models.Page[models.Review]{}, err
 	}
 	return found, nil
 }
 
-func AddReview(ctx context.Context, token string, newReviewInfo models.NewReview) (models.Review, error) {
+func AddReview(ctx context.Context, newReviewInfo models.NewReview, uploads []models.PhotoUpload) (models.Review, error) {
 	// Check if the spot exists!
 	if _, err := spotRepo.FindSpotById(ctx, newReviewInfo.SpotId); err != nil {
 		return models.Review{}, err
 	}
 
-	userName, err := auth.ExtractFromToken(token, "usr")
+	principal, err := auth.PrincipalFromContext(ctx)
 	if err != nil {
 		return models.Review{}, err
 	}
 
+	newReviewInfo.PhotoIds = generics.Unique(newReviewInfo.PhotoIds)
+	if err := ensurePhotosAreAttachable(ctx, principal.Name, newReviewInfo.PhotoIds); err != nil {
+		return models.Review{}, err
+	}
+	uploadedIds, err := uploadPhotos(ctx, principal.Name, uploads)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	photoIds := append(slices.Clone(newReviewInfo.PhotoIds), uploadedIds...)
+	photos := make([]string, 0, len(photoIds))
+	for _, id := range photoIds {
+		photos = append(photos, photoRepo.GetPhotoURL(id))
+	}
+
 	review := models.Review{
 		SpotId:    newReviewInfo.SpotId,
 		Rating:    newReviewInfo.Rating,
 		Content:   newReviewInfo.Content,
-		AddedBy:   userName,
+		AddedBy:   principal.Name,
 		CreatedAt: time.Now(),
+		Photos:    photos,
 	}
 
 	addedReview, err := reviewRepo.AddReview(ctx, review)
 	if err != nil {
+		deletePhotos(ctx, uploadedIds)
+		return models.Review{}, err
+	}
+
+	// Another review could have taken one of the referenced photos in the meantime.
+	if err := photoRepo.AttachPhotos(ctx, photoIds, addedReview.Id); err != nil {
+		if err := reviewRepo.DeleteReviewById(ctx, addedReview.Id); err != nil {
+			logger.Error("Deleting the review with unattachable photos failed: " + err.Error())
+		}
+		deletePhotos(ctx, uploadedIds)
 		return models.Review{}, err
 	}
 
+	// The review is already saved with its photos, so failing here would only make a retry add it twice.
+	if newReviewInfo.AddToSpot && len(photos) > 0 {
+		if err := spotRepo.AddPhotos(ctx, newReviewInfo.SpotId, photos); err != nil {
+			logger.Error("Adding the photos of the review " + addedReview.Id + " to the spot failed: " + err.Error())
+		}
+	}
+
+	RefreshSpotRatingAfterWrite(ctx, newReviewInfo.SpotId)
+
 	return addedReview, nil
 }
 
+// Only the photos uploaded by the user and not attached to any review yet can be attached to a new one.
+func ensurePhotosAreAttachable(ctx context.Context, userName string, photoIds []string) error {
+	for _, id := range photoIds {
+		photo, err := photoRepo.FindPhotoById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if photo.UploadedBy != userName || photo.ReviewId != "" {
+			return &apierrors.InvalidFieldsError{Fields: map[string][]string{
+				"photoIds": {"must reference your own photos not attached to another review"},
+			}}
+		}
+	}
+	return nil
+}
+
+// Uploads the new photos, removing the already uploaded ones if any of the uploads fails.
+func uploadPhotos(ctx context.Context, userName string, uploads []models.PhotoUpload) ([]string, error) {
+	ids := make([]string, 0, len(uploads))
+	for _, upload := range uploads {
+		id, err := photoRepo.UploadPhoto(ctx, upload, userName)
+		if err != nil {
+			deletePhotos(ctx, ids)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Cleans up the uploaded photos of a review that was not added. Failures are only logged, as the
+// original error is the one returned to the user.
+func deletePhotos(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		if err := photoRepo.DeletePhotoById(ctx, id); err != nil {
+			logger.Error("Deleting the uploaded photo " + id + " failed: " + err.Error())
+		}
+	}
+}
+
 func FindReviewById(ctx context.Context, id string) (models.Review, error) {
 	review, err := reviewRepo.FindReviewById(ctx, id)
 	if err != nil {
@@ -64,44 +150,238 @@ func FindReviewById(ctx context.Context, id string) (models.Review, error) {
 	return review, nil
 }
 
-func UpdateReviewById(ctx context.Context, token string, newReviewInfo models.ReviewInfo, reviewId string) (models.Review, error) {
+func UpdateReviewById(ctx context.Context, newReviewInfo models.ReviewInfo, reviewId string) (models.Review, error) {
 	review, err := reviewRepo.FindReviewById(ctx, reviewId)
 	if err != nil {
 		return models.Review{}, err
 	}
 
-	if err := auth.IsAuthorizedToEditAsset(token, review.AddedBy); err != nil {
+	if err := auth.RequireOwnerOrPermission(ctx, review.AddedBy, auth.ReviewEditAny); err != nil {
+		return models.Review{}, err
+	}
+
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
 		return models.Review{}, err
 	}
 
-	if err := reviewRepo.UpdateReviewById(ctx, reviewId, newReviewInfo); err != nil {
+	if err := reviewRepo.UpdateReviewById(ctx, reviewId, newReviewInfo, principal.Name); err != nil {
 		return models.Review{}, err
 	}
 
+	RefreshSpotRatingAfterWrite(ctx, review.SpotId)
+
 	review.Rating = newReviewInfo.Rating
 	review.Content = newReviewInfo.Content
+	review.Edited = true
 
 	return review, nil
 }
 
-func DeleteReviewById(ctx context.Context, token string, reviewId string) error {
+func GetReviewHistory(ctx context.Context, spotId string, reviewId string) ([]models.ReviewRevision, error) {
+	if err := ensureReviewIsOfSpot(ctx, spotId, reviewId); err != nil {
+		return []models.ReviewRevision{}, err
+	}
+
+	return reviewRepo.GetReviewHistory(ctx, reviewId)
+}
+
+func DeleteReviewById(ctx context.Context, reviewId string) error {
 	review, err := reviewRepo.FindReviewById(ctx, reviewId)
 	if err != nil {
 		return err
 	}
 
-	if err := auth.IsAuthorizedToEditAsset(token, review.AddedBy); err != nil {
+	if err := auth.RequireOwnerOrPermission(ctx, review.AddedBy, auth.ReviewDeleteAny); err != nil {
+		return err
+	}
+
+	if err := reviewRepo.DeleteReviewById(ctx, reviewId); err != nil {
 		return err
 	}
 
-	return reviewRepo.DeleteReviewById(ctx, reviewId)
+	RefreshSpotRatingAfterWrite(ctx, review.SpotId)
+	return nil
+}
+
+func DeleteAllReviews(ctx context.Context, spotId string) error {
+	// can delete only if the role of the user allows deleting any review.
+	if err := auth.RequirePermission(ctx, auth.ReviewDeleteAny); err != nil {
+		return err
+	}
+
+	if err := reviewRepo.DeleteAllReviews(ctx, spotId); err != nil {
+		return err
+	}
+
+	RefreshSpotRatingAfterWrite(ctx, spotId)
+	return nil
+}
+
+// Recalculates the aggregated rating stored on the spot, used for filtering and sorting spots by rating.
+func refreshSpotRating(ctx context.Context, spotId string) error {
+	rating, reviewCount, err := reviewRepo.GetRatingSummary(ctx, spotId)
+	if err != nil {
+		return err
+	}
+
+	return spotRepo.UpdateRating(ctx, spotId, rating, reviewCount)
+}
+
+// Stores the rating of the spots added before ratings were stored, returning the number of updated spots.
+func BackfillSpotRatings(ctx context.Context) (int, error) {
+	ids, err := spotRepo.GetSpotIdsWithoutRating(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for updated, id := range ids {
+		if err := refreshSpotRating(ctx, id); err != nil {
+			return updated, err
+		}
+	}
+	return len(ids), nil
+}
+
+// The write of the reviews already succeeded, so a failed recalculation is only logged instead of failing the request.
+// The rating is recalculated from all the reviews, so the next write of a review of the spot corrects it.
+func RefreshSpotRatingAfterWrite(ctx context.Context, spotId string) {
+	if err := refreshSpotRating(ctx, spotId); err != nil {
+		logger.Error("Recalculating the rating of the spot " + spotId + " failed: " + err.Error())
+	}
+}
+
+func VoteOnReview(ctx context.Context, spotId string, reviewId string, voteInfo models.ReviewVoteInfo) (models.Review, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	if err := ensureReviewIsOfSpot(ctx, spotId, reviewId); err != nil {
+		return models.Review{}, err
+	}
+
+	if err := reviewRepo.VoteOnReview(ctx, reviewId, principal.Name, *voteInfo.Helpful); err != nil {
+		return models.Review{}, err
+	}
+
+	return reviewRepo.FindReviewById(ctx, reviewId)
 }
 
-func DeleteAllReviews(ctx context.Context, token string, spotId string) error {
-	// can delete only if jwt states that the user is an admin.
-	if err := auth.IsAuthorizedToEditAsset(token, ""); err != nil {
+func DeleteVote(ctx context.Context, spotId string, reviewId string) (models.Review, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	if err := ensureReviewIsOfSpot(ctx, spotId, reviewId); err != nil {
+		return models.Review{}, err
+	}
+
+	if err := reviewRepo.DeleteVote(ctx, reviewId, principal.Name); err != nil {
+		return models.Review{}, err
+	}
+
+	return reviewRepo.FindReviewById(ctx, reviewId)
+}
+
+// Reviews of other spots are reported as missing, so the spot in the path cannot be mixed up with another one.
+func ensureReviewIsOfSpot(ctx context.Context, spotId string, reviewId string) error {
+	_, err := findReviewOfSpot(ctx, spotId, reviewId)
+	return err
+}
+
+func findReviewOfSpot(ctx context.Context, spotId string, reviewId string) (models.Review, error) {
+	review, err := reviewRepo.FindReviewById(ctx, reviewId)
+	if err != nil {
+		return models.Review{}, err
+	}
+	if review.SpotId != spotId {
+		return models.Review{}, repoerrors.ErrDoesNotExist
+	}
+	return review, nil
+}
+
+// Only the user that added the spot, or a user allowed to reply on any spot, can post the official reply.
+func AddReply(ctx context.Context, spotId string, reviewId string, replyInfo models.ReviewReplyInfo) (models.Review, error) {
+	review, err := findReviewOfSpot(ctx, spotId, reviewId)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	if review.Reply != nil {
+		return models.Review{}, repoerrors.ErrAlreadyExists
+	}
+
+	spot, err := spotRepo.FindSpotById(ctx, review.SpotId)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	if err := auth.RequireOwnerOrPermission(ctx, spot.AddedBy, auth.ReplyCreateAny); err != nil {
+		return models.Review{}, err
+	}
+
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	now := time.Now()
+	reply := models.ReviewReply{
+		Content:   replyInfo.Content,
+		AddedBy:   principal.Name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := reviewRepo.SetReply(ctx, reviewId, reply); err != nil {
+		return models.Review{}, err
+	}
+
+	review.Reply = &reply
+	return review, nil
+}
+
+func UpdateReply(ctx context.Context, spotId string, reviewId string, replyInfo models.ReviewReplyInfo) (models.Review, error) {
+	review, err := findReviewOfSpot(ctx, spotId, reviewId)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	if review.Reply == nil {
+		return models.Review{}, repoerrors.ErrDoesNotExist
+	}
+
+	if err := auth.RequireOwnerOrPermission(ctx, review.Reply.AddedBy, auth.ReplyEditAny); err != nil {
+		return models.Review{}, err
+	}
+
+	reply := *review.Reply
+	reply.Content = replyInfo.Content
+	reply.UpdatedAt = time.Now()
+
+	if err := reviewRepo.SetReply(ctx, reviewId, reply); err != nil {
+		return models.Review{}, err
+	}
+
+	review.Reply = &reply
+	return review, nil
+}
+
+func DeleteReply(ctx context.Context, spotId string, reviewId string) error {
+	review, err := findReviewOfSpot(ctx, spotId, reviewId)
+	if err != nil {
+		return err
+	}
+
+	if review.Reply == nil {
+		return repoerrors.ErrDoesNotExist
+	}
+
+	if err := auth.RequireOwnerOrPermission(ctx, review.Reply.AddedBy, auth.ReplyDeleteAny); err != nil {
 		return err
 	}
 
-	return reviewRepo.DeleteReviewById(ctx, spotId)
+	return reviewRepo.DeleteReply(ctx, reviewId)
 }
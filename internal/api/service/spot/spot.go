@@ -2,49 +2,91 @@ package spot
 
 import (
 	"context"
+	"errors"
 	"net/url"
 	"scenic-spots-api/internal/api/apierrors"
+	reviewService "scenic-spots-api/internal/api/service/review"
 	"scenic-spots-api/internal/auth"
 	"scenic-spots-api/internal/database/repositories/repoerrors"
 	reviewRepo "scenic-spots-api/internal/database/repositories/review"
 	spotRepo "scenic-spots-api/internal/database/repositories/spot"
 	"scenic-spots-api/internal/models"
-	"strconv"
+	"scenic-spots-api/utils/calc"
+	"scenic-spots-api/utils/search"
+	"strings"
 	"time"
 )
 
-func GetSpot(ctx context.Context, query url.Values) ([]models.Spot, error) {
+// Spots with similar names are compared within this radius.
+const duplicateSearchRadiusKm float64 = 0.5
+
+func GetSpot(ctx context.Context, query url.Values) (models.Page[models.Spot], error) {
 	params := models.SpotQueryParams{
-		Name:      query.Get("name"),
-		Latitude:  query.Get("latitude"),
-		Longitude: query.Get("longitude"),
-		Radius:    query.Get("radius"),
-		Category:  query.Get("category"),
-		AddedBy:   query.Get("addedBy"),
+		Name:          query.Get("name"),
+		Latitude:      query.Get("latitude"),
+		Longitude:     query.Get("longitude"),
+		Radius:        query.Get("radius"),
+		Category:      query.Get("category"),
+		AddedBy:       query.Get("addedBy"),
+		MinRating:     query.Get("minRating"),
+		HasPhotos:     query.Get("hasPhotos"),
+		CreatedAfter:  query.Get("createdAfter"),
+		CreatedBefore: query.Get("createdBefore"),
+		Sort:          query.Get("sort"),
+		Order:         query.Get("order"),
+		PageParams: models.PageParams{
+			PageSize:  query.Get("pageSize"),
+			PageToken: query.Get("pageToken"),
+		},
 	}
 
 	if (params.Latitude != "" || params.Longitude != "" || params.Radius != "") &&
 		(params.Latitude == "" || params.Longitude == "" || params.Radius == "") {
-		return nil, apierrors.ErrInvalidQueryParameters
+		return models.Page[models.Spot]{}, apierrors.ErrInvalidQueryParameters
 	}
 
 	spots, err := spotRepo.GetSpot(ctx, params)
 	if err != nil {
-		return nil, err
+		return models.Page[models.Spot]{}, err
 	}
 	return spots, nil
 }
 
-func AddSpot(ctx context.Context, token string, newSpotInfo models.NewSpot) (models.Spot, error) {
-	userName, err := auth.ExtractFromToken(token, "usr")
+func SearchSpots(ctx context.Context, query url.Values) (models.Page[models.Spot], error) {
+	params := models.SpotSearchParams{
+		Query: query.Get("q"),
+		PageParams: models.PageParams{
+			PageSize:  query.Get("pageSize"),
+			PageToken: query.Get("pageToken"),
+		},
+	}
+
+	return spotRepo.SearchSpots(ctx, params)
+}
+
+func SuggestSpots(ctx context.Context, query url.Values) ([]models.SpotSuggestion, error) {
+	params := models.SpotSuggestParams{
+		Prefix:    query.Get("prefix"),
+		Latitude:  query.Get("latitude"),
+		Longitude: query.Get("longitude"),
+		Limit:     query.Get("limit"),
+	}
+
+	return spotRepo.SuggestSpots(ctx, params)
+}
+
+func AddSpot(ctx context.Context, newSpotInfo models.NewSpot) (models.Spot, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
 	if err != nil {
 		return models.Spot{}, err
 	}
 
-	if found, err := getNearbySpots(ctx, newSpotInfo.Latitude, newSpotInfo.Longitude); err != nil {
+	if err := auth.RequireVerifiedEmail(ctx); err != nil {
+		return models.Spot{}, err
+	}
+
+	if err := ensureIsNotDuplicate(ctx, newSpotInfo, ""); err != nil {
 		return models.Spot{}, err
-	} else if len(found) > 0 {
-		return models.Spot{}, repoerrors.ErrAlreadyExists
 	}
 
 	spot := models.Spot{
@@ -54,7 +96,7 @@ func AddSpot(ctx context.Context, token string, newSpotInfo models.NewSpot) (mod
 		Longitude:   newSpotInfo.Longitude,
 		Category:    newSpotInfo.Category,
 		Photos:      []string{},
-		AddedBy:     userName,
+		AddedBy:     principal.Name,
 		CreatedAt:   time.Now(),
 	}
 
@@ -66,8 +108,14 @@ func AddSpot(ctx context.Context, token string, newSpotInfo models.NewSpot) (mod
 	return addedSpot, nil
 }
 
+// Spots merged into other ones are reported with the location of the spot they were merged into.
 func FindSpotById(ctx context.Context, id string) (models.Spot, error) {
 	spot, err := spotRepo.FindSpotById(ctx, id)
+	if errors.Is(err, repoerrors.ErrDoesNotExist) {
+		if err := EnsureIsNotMerged(ctx, id, ""); err != nil {
+			return models.Spot{}, err
+		}
+	}
 	if err != nil {
 		return models.Spot{}, err
 	}
@@ -75,19 +123,32 @@ func FindSpotById(ctx context.Context, id string) (models.Spot, error) {
 	return spot, nil
 }
 
-func UpdateSpotById(ctx context.Context, token string, newSpotInfo models.NewSpot, id string) (models.Spot, error) {
-	if found, err := getNearbySpots(ctx, newSpotInfo.Latitude, newSpotInfo.Longitude); err != nil {
-		return models.Spot{}, err
-	} else if len(found) > 0 && found[0].Id != id {
-		return models.Spot{}, repoerrors.ErrAlreadyExists
+// Returns MovedPermanentlyError with the same path under the spot the merged spot was merged into,
+// e.g. "/review/{rId}", so the reviews and photos of the merged spot keep working.
+func EnsureIsNotMerged(ctx context.Context, id string, path string) error {
+	redirect, err := spotRepo.FindRedirect(ctx, id)
+	if errors.Is(err, repoerrors.ErrDoesNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
+	return &apierrors.MovedPermanentlyError{Location: "/spot/" + redirect.TargetId + path}
+}
+
+func UpdateSpotById(ctx context.Context, newSpotInfo models.NewSpot, id string) (models.Spot, error) {
 	spot, err := spotRepo.FindSpotById(ctx, id)
 	if err != nil {
 		return models.Spot{}, err
 	}
 
-	if err := auth.IsAuthorizedToEditAsset(token, spot.AddedBy); err != nil {
+	// Checked first, so the duplicate candidates are not revealed to users who cannot edit the spot.
+	if err := auth.RequireOwnerOrPermission(ctx, spot.AddedBy, auth.SpotEditAny); err != nil {
+		return models.Spot{}, err
+	}
+
+	if err := ensureIsNotDuplicate(ctx, newSpotInfo, id); err != nil {
 		return models.Spot{}, err
 	}
 
@@ -104,13 +165,13 @@ func UpdateSpotById(ctx context.Context, token string, newSpotInfo models.NewSpo
 	return spot, nil
 }
 
-func DeleteSpotById(ctx context.Context, token string, id string) error {
+func DeleteSpotById(ctx context.Context, id string) error {
 	spot, err := spotRepo.FindSpotById(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if err := auth.IsAuthorizedToEditAsset(token, spot.AddedBy); err != nil {
+	if err := auth.RequireOwnerOrPermission(ctx, spot.AddedBy, auth.SpotDeleteAny); err != nil {
 		return err
 	}
 
@@ -121,17 +182,61 @@ func DeleteSpotById(ctx context.Context, token string, id string) error {
 	return spotRepo.DeleteSpotById(ctx, id)
 }
 
-// Check if there are no spots in 100m radius!
-func getNearbySpots(ctx context.Context, latitude float64, longitude float64) ([]models.Spot, error) {
-	found, err := spotRepo.GetSpot(ctx, models.SpotQueryParams{
-		Name:      "",
-		Latitude:  strconv.FormatFloat(latitude, 'f', -1, 64),
-		Longitude: strconv.FormatFloat(longitude, 'f', -1, 64),
-		Radius:    "0.1",
-		Category:  "",
-	})
+// Only users allowed to merge spots, admins by default, can merge them. Aggregated rating of the target is recalculated with the moved reviews.
+func MergeSpot(ctx context.Context, sourceId string, mergeInfo models.SpotMergeInfo) (models.Spot, error) {
+	if err := auth.RequirePermission(ctx, auth.SpotMerge); err != nil {
+		return models.Spot{}, err
+	}
+
+	if sourceId == mergeInfo.TargetId {
+		return models.Spot{}, &apierrors.InvalidFieldsError{Fields: map[string][]string{"targetId": {"cannot be the merged spot itself"}}}
+	}
+
+	principal, err := auth.PrincipalFromContext(ctx)
 	if err != nil {
-		return []models.Spot{}, err
+		return models.Spot{}, err
+	}
+
+	if err := spotRepo.MergeSpots(ctx, sourceId, mergeInfo.TargetId, principal.Name); err != nil {
+		return models.Spot{}, err
+	}
+
+	reviewService.RefreshSpotRatingAfterWrite(ctx, mergeInfo.TargetId)
+
+	return spotRepo.FindSpotById(ctx, mergeInfo.TargetId)
+}
+
+// Spot is a duplicate candidate if it is close and has a similar name, or if it is in the same place
+// and of the same category. The check is skipped, if the user confirmed that the spot is different.
+func ensureIsNotDuplicate(ctx context.Context, newSpotInfo models.NewSpot, id string) error {
+	if newSpotInfo.ConfirmNotDuplicate {
+		return nil
+	}
+
+	nearby, err := spotRepo.GetSpotsInRadius(ctx, newSpotInfo.Latitude, newSpotInfo.Longitude, duplicateSearchRadiusKm)
+	if err != nil {
+		return err
+	}
+
+	candidateIds := make([]string, 0)
+	for _, spot := range nearby {
+		if spot.Id == id {
+			continue
+		}
+
+		distance := calc.DistanceKm(newSpotInfo.Latitude, newSpotInfo.Longitude, spot.Latitude, spot.Longitude)
+		similarity := search.Similarity(newSpotInfo.Name, spot.Name)
+		sameCategory := strings.EqualFold(newSpotInfo.Category, spot.Category)
+
+		if (similarity >= 0.6) ||
+			(sameCategory && similarity >= 0.3 && distance <= 0.15) ||
+			(sameCategory && distance <= 0.03) {
+			candidateIds = append(candidateIds, spot.Id)
+		}
+	}
+
+	if len(candidateIds) > 0 {
+		return &apierrors.DuplicateSpotError{CandidateIds: candidateIds}
 	}
-	return found, nil
+	return nil
 }
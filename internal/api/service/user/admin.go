@@ -0,0 +1,207 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/mailer"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/logger"
+	"time"
+)
+
+// Lists the users, optionally filtered by the role and searched by the name prefix or the exact email.
+func GetUsers(ctx context.Context, query url.Values) (models.Page[models.UserView], error) {
+	if err := auth.RequirePermission(ctx, auth.UserRead); err != nil {
+		return models.Page[models.UserView]{}, err
+	}
+
+	params := models.UserQueryParams{
+		Search: query.Get("search"),
+		Role:   query.Get("role"),
+		PageParams: models.PageParams{
+			PageSize:  query.Get("pageSize"),
+			PageToken: query.Get("pageToken"),
+		},
+	}
+	if params.Role != "" && !auth.IsKnownRole(params.Role) {
+		return models.Page[models.UserView]{}, &apierrors.InvalidQueryParameterError{
+			Message: "invalid role parameter",
+		}
+	}
+
+	found, err := userAuthRepo.GetUsers(ctx, params)
+	if err != nil {
+		return models.Page[models.UserView]{}, err
+	}
+
+	views := make([]models.UserView, 0, len(found.Items))
+	for _, user := range found.Items {
+		views = append(views, toUserView(user))
+	}
+	return models.Page[models.UserView]{Items: views, NextPageToken: found.NextPageToken}, nil
+}
+
+// Users can view themselves, other users can be viewed with the permission to read them.
+func GetUserById(ctx context.Context, userId string) (models.UserView, error) {
+	user, err := userAuthRepo.FindUserById(ctx, userId)
+	if err != nil {
+		return models.UserView{}, err
+	}
+
+	if err := auth.RequireOwnerOrPermission(ctx, user.Name, auth.UserRead); err != nil {
+		return models.UserView{}, err
+	}
+
+	return toUserView(user), nil
+}
+
+// Changes the role of another user. The role applies to the already issued tokens on their next request.
+func ChangeUserRole(ctx context.Context, userId string, roleInfo models.UserRoleInfo) (models.UserView, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.UserView{}, err
+	}
+
+	if err := auth.RequirePermission(ctx, auth.UserEditRole); err != nil {
+		return models.UserView{}, err
+	}
+
+	if !auth.IsKnownRole(roleInfo.Role) {
+		return models.UserView{}, &apierrors.InvalidFieldsError{Fields: map[string][]string{"role": {"is not a known role"}}}
+	}
+	// Otherwise the last admin could take the permissions away from themselves.
+	if principal.UserId == userId {
+		return models.UserView{}, &apierrors.InvalidFieldsError{Fields: map[string][]string{"role": {"cannot be changed for yourself"}}}
+	}
+
+	if err := userAuthRepo.SetRole(ctx, userId, roleInfo.Role); err != nil {
+		return models.UserView{}, err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, userId)
+	if err != nil {
+		return models.UserView{}, err
+	}
+	return toUserView(user), nil
+}
+
+// Suspends the user until the expiry, or bans them if there is none, and ends all of their sessions.
+func SuspendUser(ctx context.Context, userId string, suspensionInfo models.UserSuspensionInfo) (models.UserView, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.UserView{}, err
+	}
+
+	if err := auth.RequirePermission(ctx, auth.UserBan); err != nil {
+		return models.UserView{}, err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, userId)
+	if err != nil {
+		return models.UserView{}, err
+	}
+
+	if err := ensureCanModerateUser(principal, user); err != nil {
+		return models.UserView{}, err
+	}
+
+	now := time.Now()
+	if suspensionInfo.ExpiresAt != nil && !suspensionInfo.ExpiresAt.After(now) {
+		return models.UserView{}, &apierrors.InvalidFieldsError{Fields: map[string][]string{"expiresAt": {"must be in the future"}}}
+	}
+
+	suspension := models.UserSuspension{
+		Reason:      suspensionInfo.Reason,
+		SuspendedBy: principal.Name,
+		SuspendedAt: now,
+		ExpiresAt:   suspensionInfo.ExpiresAt,
+	}
+	if err := userAuthRepo.SetSuspension(ctx, userId, suspension); err != nil {
+		return models.UserView{}, err
+	}
+	if err := endAllSessions(ctx, userId); err != nil {
+		return models.UserView{}, err
+	}
+
+	action := "suspended"
+	if suspension.ExpiresAt == nil {
+		action = "banned"
+	}
+	// The suspension is in place even if the notice could not be queued.
+	err = mailer.EnqueueTemplate(ctx, user.Email, mailer.ModerationNoticeTemplate, mailer.ModerationNoticeData{
+		Name:         user.Name,
+		Action:       action,
+		ContentType:  "account",
+		ContentTitle: user.Name,
+		Reason:       suspension.Reason,
+	})
+	if err != nil {
+		logger.Error("Queueing the suspension notice failed: " + err.Error())
+	}
+
+	user.Suspension = &suspension
+	return toUserView(user), nil
+}
+
+// Lifts the suspension or the ban before it expires. The user has to log in again.
+func UnsuspendUser(ctx context.Context, userId string) error {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := auth.RequirePermission(ctx, auth.UserBan); err != nil {
+		return err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureCanModerateUser(principal, user); err != nil {
+		return err
+	}
+
+	return userAuthRepo.ClearSuspension(ctx, userId)
+}
+
+// Users cannot suspend or unsuspend themselves. Users allowed to ban others can be suspended and unsuspended
+// only by users allowed to change roles, so they cannot lift each other's suspensions either.
+func ensureCanModerateUser(principal auth.Principal, user models.User) error {
+	if principal.UserId == user.Id {
+		return &apierrors.InvalidFieldsError{Fields: map[string][]string{"id": {"cannot suspend or unsuspend yourself"}}}
+	}
+	if auth.RoleHasPermission(user.Role, auth.UserBan) && !auth.RoleHasPermission(principal.Role, auth.UserEditRole) {
+		return apierrors.ErrIsUnauthorized
+	}
+	return nil
+}
+
+// Rejects issuing tokens to suspended users.
+func ensureIsNotSuspended(user models.User) error {
+	if !user.Suspension.IsActive(time.Now()) {
+		return nil
+	}
+	if user.Suspension.ExpiresAt == nil {
+		return apierrors.ErrUserSuspended
+	}
+	return fmt.Errorf("%w until %s", apierrors.ErrUserSuspended, user.Suspension.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+func toUserView(user models.User) models.UserView {
+	return models.UserView{
+		Id:               user.Id,
+		Name:             user.Name,
+		Email:            user.Email,
+		Role:             user.Role,
+		EmailVerified:    !user.Unverified,
+		TwoFactorEnabled: user.TotpEnabled,
+		Suspended:        user.Suspension.IsActive(time.Now()),
+		Suspension:       user.Suspension,
+	}
+}
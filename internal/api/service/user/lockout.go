@@ -0,0 +1,174 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	lockoutRepo "scenic-spots-api/internal/database/repositories/lockout"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Failed logins lock the account, or the IP address, once the limit is reached. Every further failure
+// doubles the lockout, up to the maximum. Counters are forgotten after a day without failures.
+const (
+	defaultAccountFailureLimit = 5
+	defaultIpFailureLimit      = 20
+	initialLockout             = time.Minute
+	maxLockout                 = time.Hour
+	failureResetWindow         = 24 * time.Hour
+)
+
+// Password reset, login link and verification emails are limited the same way, counting every request.
+// Each kind is counted under its own prefix, so one cannot use up the limit of the others.
+const (
+	defaultAccountResetLimit = 3
+	defaultIpResetLimit      = 20
+	passwordResetKeyPrefix   = "reset:"
+	magicLinkKeyPrefix       = "magic:"
+	verificationKeyPrefix    = "verify:"
+)
+
+// Rejects the login while the account or the IP address is locked.
+func ensureLoginAllowed(ctx context.Context, email string, ipAddress string) error {
+	return ensureIsNotLocked(ctx, accountKey(email), ipKey(ipAddress))
+}
+
+// Counts the failure for both the account and the IP address, and returns the error for the login.
+func recordFailedLogin(ctx context.Context, email string, ipAddress string) error {
+	err := recordAttempt(ctx, map[string]int{
+		accountKey(email): failureLimit("LOGIN_ACCOUNT_FAILURE_LIMIT", defaultAccountFailureLimit),
+		ipKey(ipAddress):  failureLimit("LOGIN_IP_FAILURE_LIMIT", defaultIpFailureLimit),
+	})
+	if err != nil {
+		return err
+	}
+
+	return apierrors.ErrInvalidCredentials
+}
+
+// Limits the password reset emails per account and IP address. They are counted under their own keys,
+// so requesting them cannot lock the logins of the account.
+func ensurePasswordResetAllowed(ctx context.Context, email string, ipAddress string) error {
+	return ensureEmailRequestAllowed(ctx, passwordResetKeyPrefix, email, ipAddress)
+}
+
+// Limits the login link emails the same way as the password reset ones.
+func ensureMagicLinkAllowed(ctx context.Context, email string, ipAddress string) error {
+	return ensureEmailRequestAllowed(ctx, magicLinkKeyPrefix, email, ipAddress)
+}
+
+// Limits the resent verification emails the same way as the password reset ones.
+func ensureVerificationEmailAllowed(ctx context.Context, email string, ipAddress string) error {
+	return ensureEmailRequestAllowed(ctx, verificationKeyPrefix, email, ipAddress)
+}
+
+func ensureEmailRequestAllowed(ctx context.Context, keyPrefix string, email string, ipAddress string) error {
+	accountRequestKey := keyPrefix + accountKey(email)
+	ipRequestKey := keyPrefix + ipKey(ipAddress)
+	if err := ensureIsNotLocked(ctx, accountRequestKey, ipRequestKey); err != nil {
+		return err
+	}
+
+	return recordAttempt(ctx, map[string]int{
+		accountRequestKey: failureLimit("PASSWORD_RESET_ACCOUNT_LIMIT", defaultAccountResetLimit),
+		ipRequestKey:      failureLimit("PASSWORD_RESET_IP_LIMIT", defaultIpResetLimit),
+	})
+}
+
+func ensureIsNotLocked(ctx context.Context, keys ...string) error {
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempts, err := lockoutRepo.GetLoginAttempts(ctx, key)
+		if err != nil {
+			return err
+		}
+		retryAfter = max(retryAfter, time.Until(attempts.LockedUntil))
+	}
+
+	if retryAfter > 0 {
+		return &apierrors.TooManyRequestsError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Counts the attempt under every key, locking the ones that reached their limit.
+func recordAttempt(ctx context.Context, limits map[string]int) error {
+	for key, limit := range limits {
+		_, err := lockoutRepo.RecordLoginFailure(ctx, key, func(attempts models.LoginAttempts) models.LoginAttempts {
+			now := time.Now()
+			if now.Sub(attempts.LastFailureAt) > failureResetWindow {
+				attempts.Failures = 0
+			}
+
+			attempts.Failures++
+			attempts.LastFailureAt = now
+			if lockout := lockoutDuration(attempts.Failures, limit); lockout > 0 {
+				attempts.LockedUntil = now.Add(lockout)
+			}
+			return attempts
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clears the counter of the account. The IP counter is kept, so one valid account cannot be used to reset it.
+func recordSuccessfulLogin(ctx context.Context, email string) error {
+	return lockoutRepo.ResetLoginAttempts(ctx, accountKey(email))
+}
+
+// Lifts the lockout of the account before it expires.
+func UnlockUser(ctx context.Context, userId string) error {
+	if err := auth.RequirePermission(ctx, auth.UserUnlock); err != nil {
+		return err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return lockoutRepo.ResetLoginAttempts(ctx, accountKey(user.Email))
+}
+
+// Reads the limit from the environment variable, 0 turns the lockout off.
+func failureLimit(variable string, defaultLimit int) int {
+	limit, err := strconv.Atoi(os.Getenv(variable))
+	if err != nil || limit < 0 {
+		return defaultLimit
+	}
+	return limit
+}
+
+func lockoutDuration(failures int, limit int) time.Duration {
+	if limit == 0 || failures < limit {
+		return 0
+	}
+
+	lockout := initialLockout
+	for i := limit; i < failures && lockout < maxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, maxLockout)
+}
+
+// Accounts are counted by the email, also the ones that do not exist, so the responses do not reveal them.
+func accountKey(email string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "account:" + hex.EncodeToString(hash[:])
+}
+
+// Hashed like the emails, so any address gives a valid document ID of a fixed length.
+func ipKey(ipAddress string) string {
+	hash := sha256.Sum256([]byte(ipAddress))
+	return "ip:" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,31 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		limit    int
+		lockout  time.Duration
+	}{
+		{failures: 10, limit: 0, lockout: 0},
+		{failures: 0, limit: 5, lockout: 0},
+		{failures: 4, limit: 5, lockout: 0},
+		{failures: 5, limit: 5, lockout: time.Minute},
+		{failures: 6, limit: 5, lockout: 2 * time.Minute},
+		{failures: 7, limit: 5, lockout: 4 * time.Minute},
+		{failures: 10, limit: 5, lockout: 32 * time.Minute},
+		{failures: 11, limit: 5, lockout: time.Hour},
+		{failures: 1000, limit: 5, lockout: time.Hour},
+		{failures: 1, limit: 1, lockout: time.Minute},
+	}
+
+	for _, test := range tests {
+		if lockout := lockoutDuration(test.failures, test.limit); lockout != test.lockout {
+			t.Errorf("lockoutDuration(%d, %d) = %v, want %v", test.failures, test.limit, lockout, test.lockout)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"os"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	tokenRepo "scenic-spots-api/internal/database/repositories/token"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/mailer"
+	"scenic-spots-api/internal/models"
+	"time"
+)
+
+const magicLinkLifetime = 15 * time.Minute
+
+// Emails a one-time login link. Unknown emails are ignored, to not reveal which accounts exist,
+// but still count towards the limit of the emails.
+func SendMagicLink(ctx context.Context, request models.MagicLinkRequest, client models.ClientInfo) error {
+	if err := ensureMagicLinkAllowed(ctx, request.Email, client.IpAddress); err != nil {
+		return err
+	}
+
+	user, err := userAuthRepo.GetUserByField(ctx, "email", request.Email)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	loginToken, hash, err := auth.CreateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = tokenRepo.AddOneTimeToken(ctx, hash, models.OneTimeToken{
+		UserId:    user.Id,
+		Purpose:   models.MagicLoginPurpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(magicLinkLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return mailer.EnqueueTemplate(ctx, user.Email, mailer.MagicLoginTemplate, mailer.MagicLoginData{
+		Name: user.Name,
+		Link: os.Getenv("APP_URL") + "/login/magic?token=" + loginToken,
+	})
+}
+
+// Logs the user in with the token from the login link. Opening the link proves the ownership of the email,
+// so an unverified email gets verified as well.
+func LoginWithMagicLink(ctx context.Context, login models.MagicLinkLogin, client models.ClientInfo) (models.UserTokenResponse, error) {
+	loginToken, err := tokenRepo.ConsumeOneTimeToken(ctx, auth.HashOneTimeToken(login.Token), models.MagicLoginPurpose)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, loginToken.UserId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	if user.Unverified {
+		if err := userAuthRepo.SetEmailVerified(ctx, user.Id); err != nil {
+			return models.UserTokenResponse{}, err
+		}
+	}
+
+	// The link replaces only the password, the second factor is still required.
+	if user.TotpEnabled {
+		return models.UserTokenResponse{}, startTwoFactorChallenge(ctx, user)
+	}
+
+	return issueTokens(ctx, user, client)
+}
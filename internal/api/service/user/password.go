@@ -0,0 +1,149 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"os"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	tokenRepo "scenic-spots-api/internal/database/repositories/token"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/mailer"
+	"scenic-spots-api/internal/models"
+	"time"
+)
+
+const passwordResetLifetime = time.Hour
+
+// Changes the password of the current user and ends all sessions, returning tokens for a new one.
+func ChangePassword(ctx context.Context, info models.PasswordChangeInfo, client models.ClientInfo) (models.UserTokenResponse, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, principal.UserId)
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	// Guessing the old password with a stolen token counts towards the same lockout as the logins.
+	if err := ensureLoginAllowed(ctx, user.Email, client.IpAddress); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+	if err := auth.ValidatePassword(ctx, user, info.OldPassword); err != nil {
+		if errors.Is(err, apierrors.ErrInvalidCredentials) {
+			return models.UserTokenResponse{}, recordFailedLogin(ctx, user.Email, client.IpAddress)
+		}
+		return models.UserTokenResponse{}, err
+	}
+	if err := recordSuccessfulLogin(ctx, user.Email); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	if err := ensurePasswordPolicy("newPassword", info.NewPassword, user.Name, user.Email); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	if err := setPassword(ctx, user.Id, info.NewPassword); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	// Reloaded for the incremented token version.
+	user, err = userAuthRepo.FindUserById(ctx, user.Id)
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	return issueTokens(ctx, user, client)
+}
+
+// Emails a password reset link. Unknown emails are ignored, to not reveal which accounts exist,
+// but they are rate limited the same as the existing ones.
+func ForgotPassword(ctx context.Context, info models.PasswordForgotInfo, client models.ClientInfo) error {
+	if err := ensurePasswordResetAllowed(ctx, info.Email, client.IpAddress); err != nil {
+		return err
+	}
+
+	user, err := userAuthRepo.GetUserByField(ctx, "email", info.Email)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	resetToken, hash, err := auth.CreateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = tokenRepo.AddOneTimeToken(ctx, hash, models.OneTimeToken{
+		UserId:    user.Id,
+		Purpose:   models.PasswordResetPurpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return mailer.EnqueueTemplate(ctx, user.Email, mailer.PasswordResetTemplate, mailer.PasswordResetData{
+		Name: user.Name,
+		Link: os.Getenv("APP_URL") + "/reset-password?token=" + resetToken,
+	})
+}
+
+// Sets the new password with the token from the reset email, and ends all sessions of the user.
+func ResetPassword(ctx context.Context, info models.PasswordResetInfo) error {
+	hash := auth.HashOneTimeToken(info.Token)
+	resetToken, err := tokenRepo.FindOneTimeToken(ctx, hash, models.PasswordResetPurpose)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return apierrors.ErrInvalidToken
+		}
+		return err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, resetToken.UserId)
+	if err != nil {
+		return err
+	}
+
+	// Checked before consuming the token, so it can be used again with a better password.
+	if err := ensurePasswordPolicy("newPassword", info.NewPassword, user.Name, user.Email); err != nil {
+		return err
+	}
+
+	if _, err := tokenRepo.ConsumeOneTimeToken(ctx, hash, models.PasswordResetPurpose); err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return apierrors.ErrInvalidToken
+		}
+		return err
+	}
+
+	return setPassword(ctx, user.Id, info.NewPassword)
+}
+
+// Rejects the password not meeting the policy, with the reasons under the given field of the request body.
+func ensurePasswordPolicy(field string, newPassword string, userInputs ...string) error {
+	if reasons := auth.CheckPasswordPolicy(newPassword, userInputs...); len(reasons) > 0 {
+		return &apierrors.InvalidFieldsError{Fields: map[string][]string{field: reasons}}
+	}
+	return nil
+}
+
+func setPassword(ctx context.Context, userId string, password string) error {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := userAuthRepo.UpdatePassword(ctx, userId, hashedPassword); err != nil {
+		return err
+	}
+
+	return endAllSessions(ctx, userId)
+}
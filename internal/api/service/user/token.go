@@ -0,0 +1,181 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	tokenRepo "scenic-spots-api/internal/database/repositories/token"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/logger"
+	"time"
+)
+
+// Exchanges a refresh token for a new access token and a new refresh token from the same family.
+// Presenting a token that was already rotated means it leaked, so the whole family is revoked.
+func RefreshToken(ctx context.Context, request models.RefreshTokenRequest, client models.ClientInfo) (models.UserTokenResponse, error) {
+	newRefreshToken, newHash, err := auth.CreateRefreshToken()
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	now := time.Now()
+	current, err := tokenRepo.RotateRefreshToken(ctx, auth.HashRefreshToken(request.RefreshToken), newHash, func(current models.RefreshToken) models.RefreshToken {
+		return models.RefreshToken{
+			UserId:    current.UserId,
+			FamilyId:  current.FamilyId,
+			CreatedAt: now,
+			ExpiresAt: now.Add(auth.RefreshTokenLifetime),
+		}
+	})
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	if current.Rotated {
+		logger.Error("Refresh token reuse detected, revoking token family " + current.FamilyId)
+		if err := tokenRepo.RevokeTokenFamily(ctx, current.FamilyId); err != nil {
+			return models.UserTokenResponse{}, err
+		}
+		return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+	}
+	if current.Revoked || now.After(current.ExpiresAt) {
+		return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+	}
+
+	if err := tokenRepo.TouchSession(ctx, current.FamilyId, client); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, current.UserId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+		}
+		return models.UserTokenResponse{}, err
+	}
+	if err := ensureIsNotSuspended(user); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	token, err := auth.CreateToken(user, current.FamilyId)
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	return models.UserTokenResponse{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+		LocalId:      user.Id,
+	}, nil
+}
+
+// Ends the session of the current token, which rejects its access tokens and revokes its refresh tokens.
+func Logout(ctx context.Context) error {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tokenRepo.RevokeTokenFamily(ctx, principal.SessionId)
+}
+
+// Invalidates every access and refresh token issued to the user so far.
+func RevokeUserSessions(ctx context.Context, userId string) error {
+	user, err := userAuthRepo.FindUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := auth.RequireOwnerOrPermission(ctx, user.Name, auth.UserRevokeSessions); err != nil {
+		return err
+	}
+
+	return endAllSessions(ctx, userId)
+}
+
+func endAllSessions(ctx context.Context, userId string) error {
+	if err := userAuthRepo.IncrementTokenVersion(ctx, userId); err != nil {
+		return err
+	}
+	return tokenRepo.RevokeUserTokens(ctx, userId)
+}
+
+// Returns the active sessions of the current user.
+func GetSessions(ctx context.Context) ([]models.Session, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenRepo.GetUserSessions(ctx, principal.UserId)
+}
+
+// Ends a single session of the current user, e.g. on a lost device.
+func DeleteSession(ctx context.Context, sessionId string) error {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := tokenRepo.FindSessionById(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	// Sessions of other users are reported as missing, to not reveal their IDs.
+	if session.UserId != principal.UserId || session.Revoked {
+		return repoerrors.ErrDoesNotExist
+	}
+
+	return tokenRepo.RevokeTokenFamily(ctx, session.Id)
+}
+
+// Starts a new session for the user, used on register and login. The session ID is the ID of its token family.
+func issueTokens(ctx context.Context, user models.User, client models.ClientInfo) (models.UserTokenResponse, error) {
+	if err := ensureIsNotSuspended(user); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	refreshToken, hash, err := auth.CreateRefreshToken()
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	now := time.Now()
+	session, err := tokenRepo.AddSession(ctx, models.Session{
+		UserId:      user.Id,
+		DeviceLabel: client.DeviceLabel,
+		IpAddress:   client.IpAddress,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+	})
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	err = tokenRepo.AddRefreshToken(ctx, hash, models.RefreshToken{
+		UserId:    user.Id,
+		FamilyId:  session.Id,
+		CreatedAt: now,
+		ExpiresAt: now.Add(auth.RefreshTokenLifetime),
+	})
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	token, err := auth.CreateToken(user, session.Id)
+	if err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	return models.UserTokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		LocalId:      user.Id,
+	}, nil
+}
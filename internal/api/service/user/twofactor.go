@@ -0,0 +1,201 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	tokenRepo "scenic-spots-api/internal/database/repositories/token"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/models"
+	"slices"
+	"time"
+)
+
+const twoFactorChallengeLifetime = 5 * time.Minute
+
+// Generates a new secret for the current user. Two-factor authentication is enabled only after
+// the first code from the authenticator app is confirmed with EnableTwoFactor.
+func SetupTwoFactor(ctx context.Context) (models.TwoFactorSetupResponse, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.TwoFactorSetupResponse{}, err
+	}
+
+	if user.TotpEnabled {
+		return models.TwoFactorSetupResponse{}, repoerrors.ErrAlreadyExists
+	}
+
+	secret, err := auth.GenerateTotpSecret()
+	if err != nil {
+		return models.TwoFactorSetupResponse{}, err
+	}
+
+	if err := userAuthRepo.SetPendingTotpSecret(ctx, user.Id, secret); err != nil {
+		return models.TwoFactorSetupResponse{}, err
+	}
+
+	return models.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TotpProvisioningURI(secret, user.Email),
+	}, nil
+}
+
+// Confirms the pending secret with a code and returns the recovery codes, which are shown only once.
+// Wrong codes count towards the same lockout as the logins.
+func EnableTwoFactor(ctx context.Context, info models.TwoFactorCodeInfo, client models.ClientInfo) (models.RecoveryCodesResponse, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.RecoveryCodesResponse{}, err
+	}
+
+	if user.TotpEnabled {
+		return models.RecoveryCodesResponse{}, repoerrors.ErrAlreadyExists
+	}
+	if user.TotpPendingSecret == "" {
+		return models.RecoveryCodesResponse{}, repoerrors.ErrDoesNotExist
+	}
+
+	if err := ensureLoginAllowed(ctx, user.Email, client.IpAddress); err != nil {
+		return models.RecoveryCodesResponse{}, err
+	}
+	step, ok := auth.ValidateTotpCode(user.TotpPendingSecret, info.Code, 0)
+	if !ok {
+		return models.RecoveryCodesResponse{}, recordFailedLogin(ctx, user.Email, client.IpAddress)
+	}
+	if err := recordSuccessfulLogin(ctx, user.Email); err != nil {
+		return models.RecoveryCodesResponse{}, err
+	}
+
+	recoveryCodes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return models.RecoveryCodesResponse{}, err
+	}
+
+	if err := userAuthRepo.EnableTotp(ctx, user.Id, user.TotpPendingSecret, step, hashes); err != nil {
+		return models.RecoveryCodesResponse{}, err
+	}
+
+	return models.RecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// Turns two-factor authentication off, requires the current password and a code or one of the recovery codes,
+// so a stolen token alone is not enough. Wrong passwords and codes count towards the same lockout as the logins.
+func DisableTwoFactor(ctx context.Context, info models.TwoFactorDisableInfo, client models.ClientInfo) error {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !user.TotpEnabled {
+		return repoerrors.ErrDoesNotExist
+	}
+
+	if err := ensureLoginAllowed(ctx, user.Email, client.IpAddress); err != nil {
+		return err
+	}
+	if err := auth.ValidatePassword(ctx, user, info.Password); err != nil {
+		if errors.Is(err, apierrors.ErrInvalidCredentials) {
+			return recordFailedLogin(ctx, user.Email, client.IpAddress)
+		}
+		return err
+	}
+	if err := checkSecondFactor(ctx, user, info.Code); err != nil {
+		if errors.Is(err, apierrors.ErrInvalidCredentials) {
+			return recordFailedLogin(ctx, user.Email, client.IpAddress)
+		}
+		return err
+	}
+	if err := recordSuccessfulLogin(ctx, user.Email); err != nil {
+		return err
+	}
+
+	return userAuthRepo.DisableTotp(ctx, user.Id)
+}
+
+// Issues the tokens once the challenge from the login is completed with a code or one of the recovery codes.
+// The challenge can be used only once, a wrong code requires logging in again.
+func CompleteTwoFactorLogin(ctx context.Context, info models.TwoFactorLoginInfo, client models.ClientInfo) (models.UserTokenResponse, error) {
+	challenge, err := tokenRepo.ConsumeOneTimeToken(ctx, auth.HashOneTimeToken(info.ChallengeToken), models.TwoFactorLoginPurpose)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, challenge.UserId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	if err := ensureLoginAllowed(ctx, user.Email, client.IpAddress); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	if err := checkSecondFactor(ctx, user, info.Code); err != nil {
+		if errors.Is(err, apierrors.ErrInvalidCredentials) {
+			return models.UserTokenResponse{}, recordFailedLogin(ctx, user.Email, client.IpAddress)
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	if err := recordSuccessfulLogin(ctx, user.Email); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
+	return issueTokens(ctx, user, client)
+}
+
+// Returns the challenge the login has to be completed with, instead of the tokens.
+func startTwoFactorChallenge(ctx context.Context, user models.User) error {
+	challengeToken, hash, err := auth.CreateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = tokenRepo.AddOneTimeToken(ctx, hash, models.OneTimeToken{
+		UserId:    user.Id,
+		Purpose:   models.TwoFactorLoginPurpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(twoFactorChallengeLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return &apierrors.TwoFactorRequiredError{ChallengeToken: challengeToken}
+}
+
+func checkSecondFactor(ctx context.Context, user models.User, code string) error {
+	used := false
+	var err error
+	if step, ok := auth.ValidateTotpCode(user.TotpSecret, code, user.TotpLastUsedStep); ok {
+		used, err = userAuthRepo.UseTotpStep(ctx, user.Id, step)
+	} else if recoveryCodeHash := auth.HashRecoveryCode(code); slices.Contains(user.RecoveryCodes, recoveryCodeHash) {
+		used, err = userAuthRepo.UseRecoveryCode(ctx, user.Id, recoveryCodeHash)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Codes used by a concurrent login in the meantime are rejected as well.
+	if !used {
+		return apierrors.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func currentUser(ctx context.Context) (models.User, error) {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return userAuthRepo.FindUserById(ctx, principal.UserId)
+}
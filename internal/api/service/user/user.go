@@ -8,9 +8,14 @@ import (
 	"scenic-spots-api/internal/database/repositories/repoerrors"
 	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
 	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/logger"
 )
 
-func RegisterUser(ctx context.Context, userRegisterInfo models.UserRegisterInfo) (models.UserTokenResponse, error) {
+func RegisterUser(ctx context.Context, userRegisterInfo models.UserRegisterInfo, client models.ClientInfo) (models.UserTokenResponse, error) {
+	if err := ensurePasswordPolicy("password", userRegisterInfo.Password, userRegisterInfo.Name, userRegisterInfo.Email); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
 	if err := ensureCredentialsUniqueness(ctx, userRegisterInfo.Name, userRegisterInfo.Email); err != nil {
 		return models.UserTokenResponse{}, err
 	}
@@ -20,10 +25,11 @@ func RegisterUser(ctx context.Context, userRegisterInfo models.UserRegisterInfo)
 	}
 
 	newUser := models.User{
-		Name:     userRegisterInfo.Name,
-		Email:    userRegisterInfo.Email,
-		Password: userRegisterInfo.Password,
-		Role:     "user", // by default
+		Name:       userRegisterInfo.Name,
+		Email:      userRegisterInfo.Email,
+		Password:   userRegisterInfo.Password,
+		Role:       auth.RoleUser, // by default
+		Unverified: true,
 	}
 
 	addedUser, err := userAuthRepo.AddUser(ctx, newUser)
@@ -31,44 +37,53 @@ func RegisterUser(ctx context.Context, userRegisterInfo models.UserRegisterInfo)
 		return models.UserTokenResponse{}, err
 	}
 
-	token, err := auth.CreateToken(addedUser)
-	if err != nil {
-		return models.UserTokenResponse{}, err
+	// The account is usable without the email, which can be sent again later.
+	if err := sendVerificationEmail(ctx, addedUser); err != nil {
+		logger.Error("Queueing the verification email failed: " + err.Error())
 	}
 
-	return models.UserTokenResponse{
-		Token:   token,
-		LocalId: addedUser.Id,
-	}, nil
+	return issueTokens(ctx, addedUser, client)
 }
 
-func LoginUser(ctx context.Context, credentials models.UserCredentials) (models.UserTokenResponse, error) {
+func LoginUser(ctx context.Context, credentials models.UserCredentials, client models.ClientInfo) (models.UserTokenResponse, error) {
+	if err := ensureLoginAllowed(ctx, credentials.Email, client.IpAddress); err != nil {
+		return models.UserTokenResponse{}, err
+	}
+
 	user, err := userAuthRepo.GetUserByField(ctx, "email", credentials.Email)
 	if err != nil {
 		if errors.Is(err, repoerrors.ErrDoesNotExist) {
-			return models.UserTokenResponse{}, apierrors.ErrInvalidCredentials
+			return models.UserTokenResponse{}, recordFailedLogin(ctx, credentials.Email, client.IpAddress)
 		}
 		return models.UserTokenResponse{}, err
 	}
 
-	token, err := auth.ValidatePasswordAndReturnToken(ctx, *user, credentials.Password)
-	if err != nil {
+	if err := auth.ValidatePassword(ctx, *user, credentials.Password); err != nil {
+		if errors.Is(err, apierrors.ErrInvalidCredentials) {
+			return models.UserTokenResponse{}, recordFailedLogin(ctx, credentials.Email, client.IpAddress)
+		}
+		return models.UserTokenResponse{}, err
+	}
+
+	// The counter is cleared only after the second factor, which can be guessed as well.
+	if user.TotpEnabled {
+		return models.UserTokenResponse{}, startTwoFactorChallenge(ctx, *user)
+	}
+
+	if err := recordSuccessfulLogin(ctx, user.Email); err != nil {
 		return models.UserTokenResponse{}, err
 	}
 
-	return models.UserTokenResponse{
-		Token:   token,
-		LocalId: user.Id,
-	}, nil
+	return issueTokens(ctx, *user, client)
 }
 
-func DeleteUserById(ctx context.Context, token string, userId string) error {
+func DeleteUserById(ctx context.Context, userId string) error {
 	user, err := userAuthRepo.FindUserById(ctx, userId)
 	if err != nil {
 		return err
 	}
 
-	if err := auth.IsAuthorizedToEditAsset(token, user.Name); err != nil {
+	if err := auth.RequireOwnerOrPermission(ctx, user.Name, auth.UserDelete); err != nil {
 		return err
 	}
 
@@ -0,0 +1,76 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"os"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/internal/auth"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	tokenRepo "scenic-spots-api/internal/database/repositories/token"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
+	"scenic-spots-api/internal/mailer"
+	"scenic-spots-api/internal/models"
+	"time"
+)
+
+const emailVerificationLifetime = 24 * time.Hour
+
+// Marks the email of the user as verified with the token from the verification email.
+func VerifyEmail(ctx context.Context, token string) error {
+	verificationToken, err := tokenRepo.ConsumeOneTimeToken(ctx, auth.HashOneTimeToken(token), models.EmailVerificationPurpose)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return apierrors.ErrInvalidToken
+		}
+		return err
+	}
+
+	return userAuthRepo.SetEmailVerified(ctx, verificationToken.UserId)
+}
+
+// Sends a new verification email to the current user, e.g. when the previous link expired.
+func ResendVerificationEmail(ctx context.Context, client models.ClientInfo) error {
+	principal, err := auth.PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.EmailVerified {
+		return repoerrors.ErrAlreadyExists
+	}
+
+	user, err := userAuthRepo.FindUserById(ctx, principal.UserId)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureVerificationEmailAllowed(ctx, user.Email, client.IpAddress); err != nil {
+		return err
+	}
+
+	return sendVerificationEmail(ctx, user)
+}
+
+func sendVerificationEmail(ctx context.Context, user models.User) error {
+	verificationToken, hash, err := auth.CreateOneTimeToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = tokenRepo.AddOneTimeToken(ctx, hash, models.OneTimeToken{
+		UserId:    user.Id,
+		Purpose:   models.EmailVerificationPurpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	return mailer.EnqueueTemplate(ctx, user.Email, mailer.VerificationTemplate, mailer.VerificationData{
+		Name: user.Name,
+		Link: os.Getenv("API_URL") + "/user/verify?token=" + verificationToken,
+	})
+}
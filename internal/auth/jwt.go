@@ -1,46 +1,119 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	tokenRepo "scenic-spots-api/internal/database/repositories/token"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
 	"scenic-spots-api/internal/models"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func CreateToken(user models.User) (string, error) {
+type Claims struct {
+	LocalId   string `json:"lid"`
+	User      string `json:"usr"`
+	Role      string `json:"rol"`
+	SessionId string `json:"sid,omitempty"`
+	Version   int    `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+func CreateToken(user models.User, sessionId string) (string, error) {
 	expirationTime := time.Now().Add(time.Hour)
 
-	claims := jwt.MapClaims{
-		"lid": user.Id,
-		"usr": user.Name,
-		"rol": user.Role,
-		"exp": expirationTime.Unix(),
+	claims := Claims{
+		LocalId:   user.Id,
+		User:      user.Name,
+		Role:      user.Role,
+		SessionId: sessionId,
+		Version:   user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	}
+
+	key, err := currentSigningKey()
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.Id
+	signedToken, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", err
 	}
 	return signedToken, nil
 }
 
-func VerifyToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-			return nil, fmt.Errorf("token has a wrong signature algorithm set")
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+// Verifies the signature with the key named in the kid header, checks the expiration of the token and returns the identity it was issued for.
+// Tokens issued for a session ended by logging out, or issued before all sessions of the user were revoked, are rejected,
+// as well as the tokens of suspended users.
+func VerifyToken(ctx context.Context, tokenString string) (Principal, error) {
+	var claims Claims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		keyId, _ := token.Header["kid"].(string)
+		return findVerificationKey(keyId)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Principal{}, fmt.Errorf("Bad token!: %w", err)
+	}
+
+	if !token.Valid {
+		return Principal{}, fmt.Errorf("Invalid token formatting / expired")
+	}
+
+	if claims.LocalId == "" || claims.User == "" || claims.Role == "" {
+		return Principal{}, fmt.Errorf("Invalid token: missing user claims")
+	}
+
+	user, err := ensureIsNotRevoked(ctx, claims)
 	if err != nil {
-		return fmt.Errorf("Bad token!: " + err.Error())
+		return Principal{}, err
+	}
+
+	return Principal{
+		UserId:        claims.LocalId,
+		Name:          claims.User,
+		Role:          user.Role,
+		SessionId:     claims.SessionId,
+		EmailVerified: !user.Unverified,
+	}, nil
+}
+
+// Returns the current state of the user the token was issued for.
+func ensureIsNotRevoked(ctx context.Context, claims Claims) (models.User, error) {
+	// Every token is issued for a session, so logging out ends the session and rejects its tokens.
+	if claims.SessionId == "" {
+		return models.User{}, fmt.Errorf("Invalid token: token has no session")
+	}
+	session, err := tokenRepo.FindSessionById(ctx, claims.SessionId)
+	if err != nil && !errors.Is(err, repoerrors.ErrDoesNotExist) {
+		return models.User{}, err
+	}
+	if err != nil || session.Revoked {
+		return models.User{}, fmt.Errorf("Invalid token: session has ended")
 	}
 
-	if _, ok := token.Claims.(jwt.MapClaims); !ok || !token.Valid {
-		return fmt.Errorf("Invalid token formatting / expired", err)
+	user, err := userAuthRepo.FindUserById(ctx, claims.LocalId)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.User{}, fmt.Errorf("Invalid token: user does not exist")
+		}
+		return models.User{}, err
+	}
+	if user.TokenVersion != claims.Version {
+		return models.User{}, fmt.Errorf("Invalid token: token was revoked")
+	}
+	if user.Suspension.IsActive(time.Now()) {
+		return models.User{}, fmt.Errorf("Invalid token: user is suspended")
 	}
 
-	return nil
+	return user, nil
 }
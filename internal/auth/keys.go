@@ -0,0 +1,361 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/logger"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultKeysDir          = "./keys"
+	defaultRotationInterval = 30 * 24 * time.Hour
+	keyRotationCheckPeriod  = time.Hour
+	// Shortest time between the reloads caused by tokens signed with unknown keys.
+	minKeyReloadPeriod = time.Minute
+	// Lock files older than this are left by an instance that failed while creating a key.
+	staleKeyLockAge = time.Minute
+	keyLockName     = "rotation.lock"
+	// Key IDs start with the creation time, so it survives copying the directory.
+	keyIdTimeLayout = "20060102T150405Z"
+	// Layout of the IDs of the keys created before the time was stored with the seconds.
+	legacyKeyIdTimeLayout = "20060102"
+)
+
+// Ed25519 key used for signing the tokens, stored as a PKCS #8 PEM file named after its ID.
+type signingKey struct {
+	Id         string
+	PrivateKey ed25519.PrivateKey
+	CreatedAt  time.Time
+}
+
+// Keys are kept in memory, the newest one signs new tokens and all of them verify the tokens signed before.
+var keyRing struct {
+	sync.RWMutex
+	keys             []signingKey
+	dir              string
+	rotationInterval time.Duration
+	lastReload       time.Time
+}
+
+var ErrUnknownSigningKey = errors.New("token is signed with an unknown key")
+
+// Loads the signing keys from JWT_KEYS_DIR, creates the first one if needed, and starts the scheduled rotation.
+func InitializeSigningKeys(ctx context.Context) error {
+	keyRing.dir = os.Getenv("JWT_KEYS_DIR")
+	if keyRing.dir == "" {
+		keyRing.dir = defaultKeysDir
+	}
+
+	keyRing.rotationInterval = defaultRotationInterval
+	if days := os.Getenv("JWT_KEY_ROTATION_DAYS"); days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("JWT_KEY_ROTATION_DAYS must be a positive number of days")
+		}
+		keyRing.rotationInterval = time.Duration(value) * 24 * time.Hour
+	}
+
+	if err := os.MkdirAll(keyRing.dir, 0o700); err != nil {
+		return err
+	}
+	if err := rotateSigningKeys(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(keyRotationCheckPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := rotateSigningKeys(); err != nil {
+					logger.Error("Signing key rotation failed: " + err.Error())
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Reloads the keys from the directory - which may be shared with other instances - and creates a new key
+// once the newest one is older than the rotation interval. Retired keys stay valid for verification for another
+// interval, long after the tokens they signed expire, and are removed afterwards.
+func rotateSigningKeys() error {
+	keys, err := loadSigningKeys(keyRing.dir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if needsNewKey(keys, now) {
+		keys, err = createSigningKeyOnce(keyRing.dir, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	validKeys := make([]signingKey, 0, len(keys))
+	for i, key := range keys {
+		if i < len(keys)-1 && now.Sub(keys[i+1].CreatedAt) >= keyRing.rotationInterval {
+			if err := os.Remove(keyPath(keyRing.dir, key.Id)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			logger.Info("Removed the retired signing key " + key.Id)
+			continue
+		}
+		validKeys = append(validKeys, key)
+	}
+
+	keyRing.Lock()
+	keyRing.keys = validKeys
+	keyRing.lastReload = now
+	keyRing.Unlock()
+	return nil
+}
+
+func needsNewKey(keys []signingKey, now time.Time) bool {
+	return len(keys) == 0 || now.Sub(keys[len(keys)-1].CreatedAt) >= keyRing.rotationInterval
+}
+
+// Instances sharing the directory create the key under a lock file, so only one of them creates it.
+// The others keep the current keys and pick the new one up on the next reload, unless there is no key
+// at all yet - then they wait for it.
+func createSigningKeyOnce(dir string, now time.Time) ([]signingKey, error) {
+	lockPath := filepath.Join(dir, keyLockName)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			lock.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleKeyLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		keys, err := loadSigningKeys(dir)
+		if err != nil || len(keys) > 0 {
+			return keys, err
+		}
+		time.Sleep(time.Second)
+	}
+	defer os.Remove(lockPath)
+
+	// Another instance could have created the key before this one took the lock.
+	keys, err := loadSigningKeys(dir)
+	if err != nil || !needsNewKey(keys, now) {
+		return keys, err
+	}
+
+	key, err := createSigningKey(dir, now)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Created a new signing key " + key.Id)
+	return append(keys, key), nil
+}
+
+// Loads the keys created by other instances sharing the directory, at most once per minKeyReloadPeriod.
+func reloadSigningKeys() error {
+	keyRing.RLock()
+	recentlyReloaded := time.Since(keyRing.lastReload) < minKeyReloadPeriod
+	keyRing.RUnlock()
+	if recentlyReloaded {
+		return nil
+	}
+
+	keys, err := loadSigningKeys(keyRing.dir)
+	if err != nil {
+		return err
+	}
+
+	keyRing.Lock()
+	keyRing.lastReload = time.Now()
+	if len(keys) > 0 {
+		keyRing.keys = keys
+	}
+	keyRing.Unlock()
+	return nil
+}
+
+func loadSigningKeys(dir string) ([]signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]signingKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid signing key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func loadSigningKey(path string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return signingKey{}, fmt.Errorf("expected a PEM encoded private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return signingKey{}, err
+	}
+	privateKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return signingKey{}, fmt.Errorf("expected an Ed25519 key")
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), ".pem")
+	createdAt, err := keyCreationTime(id)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	return signingKey{
+		Id:         id,
+		PrivateKey: privateKey,
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+func keyCreationTime(id string) (time.Time, error) {
+	prefix, _, _ := strings.Cut(id, "-")
+	for _, layout := range []string{keyIdTimeLayout, legacyKeyIdTimeLayout} {
+		if createdAt, err := time.Parse(layout, prefix); err == nil {
+			return createdAt, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("key ID must start with the creation time")
+}
+
+func createSigningKey(dir string, createdAt time.Time) (signingKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return signingKey{}, err
+	}
+	createdAt = createdAt.UTC().Truncate(time.Second)
+	id := createdAt.Format(keyIdTimeLayout) + "-" + hex.EncodeToString(suffix)
+
+	// Never overwrites an existing key, which could still verify the tokens it signed.
+	file, err := os.OpenFile(keyPath(dir, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return signingKey{}, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(keyPath(dir, id))
+		return signingKey{}, err
+	}
+	if err := file.Close(); err != nil {
+		return signingKey{}, err
+	}
+
+	return signingKey{Id: id, PrivateKey: privateKey, CreatedAt: createdAt}, nil
+}
+
+func keyPath(dir string, id string) string {
+	return filepath.Join(dir, id+".pem")
+}
+
+func currentSigningKey() (signingKey, error) {
+	keyRing.RLock()
+	defer keyRing.RUnlock()
+
+	if len(keyRing.keys) == 0 {
+		return signingKey{}, fmt.Errorf("signing keys are not initialized")
+	}
+	return keyRing.keys[len(keyRing.keys)-1], nil
+}
+
+// Unknown keys can be the ones just created by another instance sharing the directory, so the keys are
+// reloaded once before the token is rejected.
+func findVerificationKey(id string) (ed25519.PublicKey, error) {
+	if key, ok := findLoadedKey(id); ok {
+		return key, nil
+	}
+
+	if err := reloadSigningKeys(); err != nil {
+		logger.Error("Reloading the signing keys failed: " + err.Error())
+	}
+	if key, ok := findLoadedKey(id); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownSigningKey
+}
+
+func findLoadedKey(id string) (ed25519.PublicKey, bool) {
+	keyRing.RLock()
+	defer keyRing.RUnlock()
+
+	for _, key := range keyRing.keys {
+		if key.Id == id {
+			return key.PrivateKey.Public().(ed25519.PublicKey), true
+		}
+	}
+	return nil, false
+}
+
+// Returns the public parts of all keys that are valid for verification.
+func PublicKeys() models.JSONWebKeySet {
+	keyRing.RLock()
+	defer keyRing.RUnlock()
+
+	keySet := models.JSONWebKeySet{Keys: make([]models.JSONWebKey, 0, len(keyRing.keys))}
+	for _, key := range keyRing.keys {
+		keySet.Keys = append(keySet.Keys, models.JSONWebKey{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(key.PrivateKey.Public().(ed25519.PublicKey)),
+			KeyId:     key.Id,
+			Algorithm: jwt.SigningMethodEdDSA.Alg(),
+			Use:       "sig",
+		})
+	}
+	return keySet
+}
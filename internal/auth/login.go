@@ -3,20 +3,35 @@ package auth
 import (
 	"context"
 	"scenic-spots-api/internal/api/apierrors"
+	userAuthRepo "scenic-spots-api/internal/database/repositories/user"
 	"scenic-spots-api/internal/models"
-
-	"golang.org/x/crypto/bcrypt"
+	"scenic-spots-api/utils/logger"
 )
 
-func ValidatePasswordAndReturnToken(ctx context.Context, userInfo models.User, password string) (string, error) {
-	if err := bcrypt.CompareHashAndPassword([]byte(userInfo.Password), []byte(password)); err != nil {
-		return "", apierrors.ErrInvalidCredentials
+// Checks the password of the user, and upgrades the stored hash to the current format once it is known to be correct.
+func ValidatePassword(ctx context.Context, userInfo models.User, password string) error {
+	valid, needsRehash, err := VerifyPassword(userInfo.Password, password)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return apierrors.ErrInvalidCredentials
 	}
 
-	token, err := CreateToken(userInfo)
-	if err != nil {
-		return "", err
+	if needsRehash {
+		// A failed upgrade does not fail the login, it is tried again with the next one.
+		if err := rehashPassword(ctx, userInfo.Id, password); err != nil {
+			logger.Error("Upgrading the password hash of user " + userInfo.Id + " failed: " + err.Error())
+		}
 	}
 
-	return token, nil
+	return nil
+}
+
+func rehashPassword(ctx context.Context, userId string, password string) error {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return userAuthRepo.UpdatePassword(ctx, userId, hashed)
 }
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"scenic-spots-api/internal/api/apierrors"
+	"scenic-spots-api/utils/logger"
+	"slices"
+)
+
+// Permissions allow acting on the content of other users, or managing them. Everyone can edit their own content.
+type Permission string
+
+const (
+	SpotEditAny          Permission = "spot:edit:any"
+	SpotDeleteAny        Permission = "spot:delete:any"
+	SpotMerge            Permission = "spot:merge"
+	ReviewEditAny        Permission = "review:edit:any"
+	ReviewDeleteAny      Permission = "review:delete:any"
+	ReplyCreateAny       Permission = "reply:create:any"
+	ReplyEditAny         Permission = "reply:edit:any"
+	ReplyDeleteAny       Permission = "reply:delete:any"
+	UserRead             Permission = "user:read"
+	UserEditRole         Permission = "user:edit:role"
+	UserBan              Permission = "user:ban"
+	UserUnlock           Permission = "user:unlock"
+	UserRevokeSessions   Permission = "user:sessions:revoke"
+	UserDelete           Permission = "user:delete"
+	allPermissionsMarker            = "*"
+)
+
+var knownPermissions = []Permission{
+	SpotEditAny, SpotDeleteAny, SpotMerge,
+	ReviewEditAny, ReviewDeleteAny,
+	ReplyCreateAny, ReplyEditAny, ReplyDeleteAny,
+	UserRead, UserEditRole, UserBan, UserUnlock, UserRevokeSessions, UserDelete,
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Built-in mapping of the roles, used when ROLE_PERMISSIONS_FILE is not set. Moderators only moderate the content,
+// merging spots and suspending users is left to the admins.
+//
+//go:embed roles.json
+var defaultRolePermissionsFile []byte
+
+var rolePermissions = func() map[string][]Permission {
+	permissions, err := parseRolePermissions(defaultRolePermissionsFile)
+	if err != nil {
+		panic("Invalid built-in role permissions: " + err.Error())
+	}
+	return permissions
+}()
+
+// Loads the mapping of roles to permissions from the JSON file in ROLE_PERMISSIONS_FILE, e.g. {"moderator": ["spot:edit:any"]},
+// in the same format as the built-in internal/auth/roles.json. The "*" permission grants all of them. Roles missing from the file have no permissions.
+func InitializeRolePermissions() error {
+	path := os.Getenv("ROLE_PERMISSIONS_FILE")
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading the role permissions file: %w", err)
+	}
+
+	permissions, err := parseRolePermissions(data)
+	if err != nil {
+		return fmt.Errorf("Error in the role permissions file: %w", err)
+	}
+
+	rolePermissions = permissions
+	logger.Info(fmt.Sprintf("Loaded the permissions of %d roles", len(rolePermissions)))
+	return nil
+}
+
+func parseRolePermissions(data []byte) (map[string][]Permission, error) {
+	var config map[string][]string
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if _, ok := config[RoleUser]; !ok {
+		return nil, fmt.Errorf("the %q role must be defined", RoleUser)
+	}
+	for role, permissions := range config {
+		for _, permission := range permissions {
+			if permission != allPermissionsMarker && !slices.Contains(knownPermissions, Permission(permission)) {
+				return nil, fmt.Errorf("unknown permission %q of the %q role", permission, role)
+			}
+		}
+	}
+
+	return resolveRolePermissions(config), nil
+}
+
+func resolveRolePermissions(config map[string][]string) map[string][]Permission {
+	resolved := make(map[string][]Permission, len(config))
+	for role, permissions := range config {
+		resolved[role] = []Permission{}
+		for _, permission := range permissions {
+			if permission == allPermissionsMarker {
+				resolved[role] = slices.Clone(knownPermissions)
+				break
+			}
+			resolved[role] = append(resolved[role], Permission(permission))
+		}
+	}
+	return resolved
+}
+
+// Returns if the role is defined in the permissions config.
+func IsKnownRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func RoleHasPermission(role string, permission Permission) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// Allows the action only to users whose role has the permission.
+func RequirePermission(ctx context.Context, permission Permission) error {
+	principal, err := PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !RoleHasPermission(principal.Role, permission) {
+		return apierrors.ErrIsUnauthorized
+	}
+
+	return nil
+}
+
+// Allows the action to the owner of the asset, and to users whose role has the permission for the assets of others.
+func RequireOwnerOrPermission(ctx context.Context, owner string, permission Permission) error {
+	principal, err := PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if principal.Name != owner && !RoleHasPermission(principal.Role, permission) {
+		return apierrors.ErrIsUnauthorized
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"scenic-spots-api/utils/logger"
+	"scenic-spots-api/utils/password"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 128
+	defaultPasswordMinScore  = 2
+	maxPasswordScore         = 4
+)
+
+type passwordPolicyConfig struct {
+	minLength int
+	maxLength int
+	minScore  int
+	// SHA-1 hashes of the breached passwords, sorted for the binary search.
+	breachedHashes [][sha1.Size]byte
+}
+
+var passwordPolicy = passwordPolicyConfig{
+	minLength: defaultPasswordMinLength,
+	maxLength: defaultPasswordMaxLength,
+	minScore:  defaultPasswordMinScore,
+}
+
+// Hints for the patterns that made the password easy to guess.
+var passwordPatternReasons = map[string]string{
+	password.DictionaryPattern: "contains a commonly used password or word",
+	password.UserInputPattern:  "contains the name or email of the user",
+	password.SequencePattern:   "contains a sequence like abc or 123",
+	password.RepeatPattern:     "contains repeated characters like aaa",
+	password.KeyboardPattern:   "contains a keyboard pattern like qwerty",
+	password.DatePattern:       "contains a date or a year",
+}
+
+// Reads the policy from PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH and PASSWORD_MIN_SCORE, and loads the
+// breached passwords from PASSWORD_BREACHED_FILE, if set.
+func InitializePasswordPolicy() error {
+	var err error
+	if passwordPolicy.minLength, err = policyNumber("PASSWORD_MIN_LENGTH", defaultPasswordMinLength, 1, defaultPasswordMaxLength); err != nil {
+		return err
+	}
+	if passwordPolicy.maxLength, err = policyNumber("PASSWORD_MAX_LENGTH", defaultPasswordMaxLength, passwordPolicy.minLength, 1024); err != nil {
+		return err
+	}
+	if passwordPolicy.minScore, err = policyNumber("PASSWORD_MIN_SCORE", defaultPasswordMinScore, 0, maxPasswordScore); err != nil {
+		return err
+	}
+
+	path := os.Getenv("PASSWORD_BREACHED_FILE")
+	if path == "" {
+		return nil
+	}
+	if passwordPolicy.breachedHashes, err = loadBreachedHashes(path); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("Loaded %d breached password hashes", len(passwordPolicy.breachedHashes)))
+	return nil
+}
+
+// Returns the reasons the password is rejected, empty when it satisfies the policy.
+// The user inputs, e.g. the name and email, should not make up the password.
+func CheckPasswordPolicy(newPassword string, userInputs ...string) []string {
+	var reasons []string
+
+	length := utf8.RuneCountInString(newPassword)
+	if length < passwordPolicy.minLength {
+		reasons = append(reasons, fmt.Sprintf("must be at least %d characters long", passwordPolicy.minLength))
+	}
+	if length > passwordPolicy.maxLength {
+		// Long passwords are not estimated, the estimation time grows with the square of the length.
+		return append(reasons, fmt.Sprintf("must be at most %d characters long", passwordPolicy.maxLength))
+	}
+
+	if isBreachedPassword(newPassword) {
+		reasons = append(reasons, "appears in a list of passwords exposed in data breaches")
+	}
+
+	strength := password.Estimate(newPassword, userInputs...)
+	if strength.Score < passwordPolicy.minScore {
+		reasons = append(reasons, fmt.Sprintf("is too easy to guess, its strength is %d out of %d and at least %d is required",
+			strength.Score, maxPasswordScore, passwordPolicy.minScore))
+		for _, pattern := range strength.Patterns {
+			reasons = append(reasons, passwordPatternReasons[pattern])
+		}
+	}
+
+	return reasons
+}
+
+func isBreachedPassword(newPassword string) bool {
+	if len(passwordPolicy.breachedHashes) == 0 {
+		return false
+	}
+	hash := sha1.Sum([]byte(newPassword))
+	_, found := slices.BinarySearchFunc(passwordPolicy.breachedHashes, hash, compareHashes)
+	return found
+}
+
+// Reads a file of uppercase or lowercase hex SHA-1 hashes, one per line, optionally followed by ":<count>"
+// like in the Have I Been Pwned downloads. The file should be sorted, otherwise it is sorted after loading.
+func loadBreachedHashes(path string) ([][sha1.Size]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the breached passwords file: %w", err)
+	}
+	defer file.Close()
+
+	var hashes [][sha1.Size]byte
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hexHash, _, _ := strings.Cut(line, ":")
+
+		var hash [sha1.Size]byte
+		if len(hexHash) != hex.EncodedLen(sha1.Size) {
+			return nil, fmt.Errorf("Invalid SHA-1 hash in line %d of the breached passwords file", lineNumber)
+		}
+		if _, err := hex.Decode(hash[:], []byte(hexHash)); err != nil {
+			return nil, fmt.Errorf("Invalid SHA-1 hash in line %d of the breached passwords file", lineNumber)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading the breached passwords file: %w", err)
+	}
+
+	if !slices.IsSortedFunc(hashes, compareHashes) {
+		slices.SortFunc(hashes, compareHashes)
+	}
+	return hashes, nil
+}
+
+func compareHashes(a [sha1.Size]byte, b [sha1.Size]byte) int {
+	return bytes.Compare(a[:], b[:])
+}
+
+func policyNumber(name string, defaultValue int, minValue int, maxValue int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < minValue || number > maxValue {
+		return 0, fmt.Errorf("%s must be a number between %d and %d", name, minValue, maxValue)
+	}
+	return number, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"scenic-spots-api/internal/api/apierrors"
+)
+
+// Identity of the user verified from the JWT token by the authentication middleware.
+type Principal struct {
+	UserId string
+	Name   string
+	// Read from the user on every request, so a role change applies to the tokens issued before.
+	Role string
+	// ID of the login session the token was issued in, used for revoking it.
+	SessionId string
+	// Read from the user on every request, so it changes as soon as the email is verified.
+	EmailVerified bool
+}
+
+type principalContextKey struct{}
+
+var ErrNoPrincipal = errors.New("request is not authenticated")
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, error) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	if !ok {
+		return Principal{}, ErrNoPrincipal
+	}
+	return principal, nil
+}
+
+// Unverified users can browse, but cannot add new content.
+func RequireVerifiedEmail(ctx context.Context) error {
+	principal, err := PrincipalFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !principal.EmailVerified {
+		return apierrors.ErrEmailNotVerified
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const RefreshTokenLifetime = 30 * 24 * time.Hour
+
+// Returns a random refresh token, which is handed to the user, and its hash, which is stored in the database.
+func CreateRefreshToken() (string, string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+	return token, HashRefreshToken(token), nil
+}
+
+// Refresh tokens are random, so a fast hash is enough to protect them if the database leaks.
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// One-time tokens sent by email are generated and stored the same way as the refresh tokens.
+func CreateOneTimeToken() (string, string, error) {
+	return CreateRefreshToken()
+}
+
+func HashOneTimeToken(token string) string {
+	return HashRefreshToken(token)
+}
@@ -0,0 +1,26 @@
+package auth
+
+import "testing"
+
+// Test vectors of RFC 6238 appendix B for SHA-1, truncated to the last 6 of the 8 digits.
+func TestTotpCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{time: 59, code: "287082"},
+		{time: 1111111109, code: "081804"},
+		{time: 1111111111, code: "050471"},
+		{time: 1234567890, code: "005924"},
+		{time: 2000000000, code: "279037"},
+		{time: 20000000000, code: "353130"},
+	}
+
+	for _, test := range tests {
+		if code := totpCode(key, test.time/totpPeriod); code != test.code {
+			t.Errorf("totpCode at %d = %q, want %q", test.time, code, test.code)
+		}
+	}
+}
@@ -45,8 +45,16 @@ func readFileToStruct[T any](filePath string) (map[string]T, error) {
 	return itemMap, nil
 }
 
+// Implemented by the items that store a search index next to their data.
+type searchIndexable interface {
+	IndexForSearch()
+}
+
 func addToDatabase[T ~map[string]V, V any](ctx context.Context, client *firestore.Client, collectionName string, items T) error {
 	for id, item := range items {
+		if indexable, ok := any(&item).(searchIndexable); ok {
+			indexable.IndexForSearch()
+		}
 		jsonItem, err := generics.StructToMapLower(item)
 		if err != nil {
 			return err
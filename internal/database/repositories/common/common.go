@@ -2,15 +2,24 @@ package common
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"scenic-spots-api/internal/api/apierrors"
 	"scenic-spots-api/internal/database"
 	"scenic-spots-api/internal/database/repositories/repoerrors"
 	"scenic-spots-api/internal/models"
 	"scenic-spots-api/utils/generics"
+	"strconv"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+const DefaultPageSize int = 50
+const MaxPageSize int = 100
+
 func FindItemById[T models.Identifiable](ctx context.Context, collectionName string, id string) (T, error) {
 	client := database.GetFirestoreClient()
 	docRef := client.Collection(collectionName).Doc(id)
@@ -59,6 +68,106 @@ func GetAllItems[T models.Identifiable](ctx context.Context, query firestore.Que
 	return found, nil
 }
 
+// Returns a single page of the query results. The page token is the encoded ID of the last document
+// on the previous page, which is used as the StartAfter cursor of the query.
+func GetPage[T models.Identifiable](ctx context.Context, collectionRef *firestore.CollectionRef, query firestore.Query, params models.PageParams) (models.Page[T], error) {
+	pageSize, err := ParsePageSize(params.PageSize)
+	if err != nil {
+		return models.Page[T]{}, &apierrors.InvalidQueryParameterError{
+			Message: err.Error(),
+		}
+	}
+
+	if params.PageToken != "" {
+		cursor, err := decodePageToken(ctx, collectionRef, params.PageToken)
+		if err != nil {
+			return models.Page[T]{}, err
+		}
+		query = query.StartAfter(cursor)
+	}
+
+	// One document more than requested tells if there is a next page.
+	found, err := GetAllItems[T](ctx, query.Limit(pageSize+1))
+	if err != nil {
+		return models.Page[T]{}, err
+	}
+
+	page := models.Page[T]{Items: found}
+	if len(found) > pageSize {
+		page.Items = found[:pageSize]
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(found[pageSize-1].GetId()))
+	}
+
+	return page, nil
+}
+
+func ParsePageSize(pageSize string) (int, error) {
+	if pageSize == "" {
+		return DefaultPageSize, nil
+	}
+
+	size, err := strconv.Atoi(pageSize)
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("invalid pageSize parameter")
+	}
+	if size > MaxPageSize {
+		return MaxPageSize, nil
+	}
+	return size, nil
+}
+
+func decodePageToken(ctx context.Context, collectionRef *firestore.CollectionRef, pageToken string) (*firestore.DocumentSnapshot, error) {
+	invalidTokenErr := &apierrors.InvalidQueryParameterError{
+		Message: "invalid pageToken parameter",
+	}
+
+	id, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil || len(id) == 0 {
+		return nil, invalidTokenErr
+	}
+
+	doc, err := collectionRef.Doc(string(id)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, invalidTokenErr
+		}
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Pages the results ranked in memory, where there is no document to start after. The page token holds the
+// offset of the next page, so the pages stay consistent only as long as the results do not change.
+func PageOffset[T any](items []T, params models.PageParams) (models.Page[T], error) {
+	pageSize, err := ParsePageSize(params.PageSize)
+	if err != nil {
+		return models.Page[T]{}, &apierrors.InvalidQueryParameterError{
+			Message: err.Error(),
+		}
+	}
+
+	offset := 0
+	if params.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(params.PageToken)
+		if err == nil {
+			offset, err = strconv.Atoi(string(decoded))
+		}
+		if err != nil || offset < 1 || offset > len(items) {
+			return models.Page[T]{}, &apierrors.InvalidQueryParameterError{
+				Message: "invalid pageToken parameter",
+			}
+		}
+	}
+
+	end := min(offset+pageSize, len(items))
+	page := models.Page[T]{Items: items[offset:end]}
+	if end < len(items) {
+		page.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	}
+	return page, nil
+}
+
 func AddItem[T models.Identifiable](ctx context.Context, collectionName string, item T) (T, error) {
 	// Casting to a json to avoid capitalized words in database.
 	data, err := generics.StructToMapLower(item)
@@ -0,0 +1,67 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"scenic-spots-api/internal/database"
+	"scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/generics"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Returns the counter with the key, or an empty one if there were no failures yet.
+func GetLoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error) {
+	attempts, err := common.FindItemById[*models.LoginAttempts](ctx, models.LoginAttemptsCollectionName, key)
+	if err != nil {
+		if errors.Is(err, repoerrors.ErrDoesNotExist) {
+			return models.LoginAttempts{Id: key}, nil
+		}
+		return models.LoginAttempts{}, err
+	}
+
+	return *attempts, nil
+}
+
+// Updates the counter in a transaction, so concurrent failed attempts are all counted.
+func RecordLoginFailure(ctx context.Context, key string, update func(models.LoginAttempts) models.LoginAttempts) (models.LoginAttempts, error) {
+	client := database.GetFirestoreClient()
+	attemptsRef := client.Collection(models.LoginAttemptsCollectionName).Doc(key)
+
+	var updated models.LoginAttempts
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		current := models.LoginAttempts{}
+		doc, err := tx.Get(attemptsRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&current); err != nil {
+				return err
+			}
+		}
+		current.SetId(key)
+
+		updated = update(current)
+		data, err := generics.StructToMapLower(updated)
+		if err != nil {
+			return err
+		}
+		return tx.Set(attemptsRef, data)
+	})
+	if err != nil {
+		return models.LoginAttempts{}, err
+	}
+
+	return updated, nil
+}
+
+func ResetLoginAttempts(ctx context.Context, key string) error {
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.LoginAttemptsCollectionName).Doc(key).Delete(ctx)
+	return err
+}
@@ -0,0 +1,105 @@
+package outbox
+
+import (
+	"context"
+	"scenic-spots-api/internal/database"
+	"scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+func AddMessage(ctx context.Context, message models.OutboxMessage) (models.OutboxMessage, error) {
+	addedMessage, err := common.AddItem(ctx, models.MailOutboxCollectionName, &message)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+
+	return *addedMessage, nil
+}
+
+// Returns up to limit pending messages that are due, each claimed for the lease duration by moving its next attempt,
+// so other API instances do not send it at the same time. Messages of a crashed instance are retried after the lease.
+func ClaimDueMessages(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.MailOutboxCollectionName).
+		Where("status", "==", models.OutboxStatusPending).
+		Where("nextAttemptAt", "<=", time.Now()).
+		OrderBy("nextAttemptAt", firestore.Asc).
+		Limit(limit)
+
+	due, err := common.GetAllItems[*models.OutboxMessage](ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := []models.OutboxMessage{}
+	for _, message := range due {
+		ok, err := claimMessage(ctx, message.Id, lease)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			claimed = append(claimed, *message)
+		}
+	}
+
+	return claimed, nil
+}
+
+func claimMessage(ctx context.Context, id string, lease time.Duration) (bool, error) {
+	client := database.GetFirestoreClient()
+	messageRef := client.Collection(models.MailOutboxCollectionName).Doc(id)
+
+	claimed := false
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = false
+		doc, err := tx.Get(messageRef)
+		if err != nil {
+			return err
+		}
+
+		var message models.OutboxMessage
+		if err := doc.DataTo(&message); err != nil {
+			return err
+		}
+		now := time.Now()
+		if message.Status != models.OutboxStatusPending || message.NextAttemptAt.After(now) {
+			return nil
+		}
+
+		claimed = true
+		return tx.Update(messageRef, []firestore.Update{{Path: "nextAttemptAt", Value: now.Add(lease)}})
+	})
+	return claimed, err
+}
+
+func MarkSent(ctx context.Context, id string, attempts int) error {
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.MailOutboxCollectionName).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.OutboxStatusSent},
+		{Path: "attempts", Value: attempts},
+		{Path: "sentAt", Value: time.Now()},
+		{Path: "text", Value: ""},
+		{Path: "html", Value: ""},
+	})
+	return err
+}
+
+// Records the failed attempt. The message is retried at nextAttemptAt, unless the status is set to failed.
+func MarkAttemptFailed(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, lastError string, status string) error {
+	updates := []firestore.Update{
+		{Path: "status", Value: status},
+		{Path: "attempts", Value: attempts},
+		{Path: "nextAttemptAt", Value: nextAttemptAt},
+		{Path: "lastError", Value: lastError},
+	}
+	if status == models.OutboxStatusFailed {
+		updates = append(updates, firestore.Update{Path: "text", Value: ""}, firestore.Update{Path: "html", Value: ""})
+	}
+
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.MailOutboxCollectionName).Doc(id).Update(ctx, updates)
+	return err
+}
@@ -0,0 +1,109 @@
+package photo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"scenic-spots-api/internal/database"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	"scenic-spots-api/internal/models"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/googleapi"
+)
+
+const photoPathPrefix string = "photos/"
+
+// Names of the object metadata keys.
+const (
+	uploadedByKey = "uploadedBy"
+	reviewIdKey   = "reviewId"
+)
+
+// Uploads the photo to the storage bucket and returns its generated ID.
+func UploadPhoto(ctx context.Context, photo models.PhotoUpload, uploadedBy string) (string, error) {
+	id := uuid.NewString()
+
+	writer := database.GetStorageBucketHandle().Object(photoPathPrefix + id).NewWriter(ctx)
+	writer.ContentType = photo.ContentType
+	writer.Metadata = map[string]string{uploadedByKey: uploadedBy}
+	if _, err := writer.Write(photo.Data); err != nil {
+		writer.Close()
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func FindPhotoById(ctx context.Context, id string) (models.Photo, error) {
+	attrs, err := database.GetStorageBucketHandle().Object(photoPathPrefix + id).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return models.Photo{}, repoerrors.ErrDoesNotExist
+		}
+		return models.Photo{}, err
+	}
+
+	return models.Photo{
+		Id:         id,
+		URL:        GetPhotoURL(id),
+		UploadedBy: attrs.Metadata[uploadedByKey],
+		ReviewId:   attrs.Metadata[reviewIdKey],
+	}, nil
+}
+
+func GetPhotoURL(id string) string {
+	return database.GetPublicObjectURL(photoPathPrefix + id)
+}
+
+// Attaches the photos to the review. Photos already attached to another review, including ones attached
+// concurrently, return ErrAlreadyExists and the photos attached up to that point are detached again.
+func AttachPhotos(ctx context.Context, ids []string, reviewId string) error {
+	for i, id := range ids {
+		if err := setPhotoReview(ctx, id, "", reviewId); err != nil {
+			for _, attachedId := range ids[:i] {
+				setPhotoReview(ctx, attachedId, reviewId, "")
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func DeletePhotoById(ctx context.Context, id string) error {
+	err := database.GetStorageBucketHandle().Object(photoPathPrefix + id).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return repoerrors.ErrDoesNotExist
+	}
+	return err
+}
+
+// Replaces the review the photo is attached to, as long as it is still the expected one. The metageneration
+// precondition makes the check and the update atomic.
+func setPhotoReview(ctx context.Context, id string, expectedReviewId string, reviewId string) error {
+	object := database.GetStorageBucketHandle().Object(photoPathPrefix + id)
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return repoerrors.ErrDoesNotExist
+		}
+		return err
+	}
+	if attrs.Metadata[reviewIdKey] != expectedReviewId {
+		return repoerrors.ErrAlreadyExists
+	}
+
+	metadata := map[string]string{uploadedByKey: attrs.Metadata[uploadedByKey], reviewIdKey: reviewId}
+	_, err = object.If(storage.Conditions{MetagenerationMatch: attrs.Metageneration}).Update(ctx, storage.ObjectAttrsToUpdate{
+		Metadata: metadata,
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return repoerrors.ErrAlreadyExists
+	}
+	return err
+}
@@ -6,53 +6,73 @@ import (
 	"scenic-spots-api/internal/api/apierrors"
 	"scenic-spots-api/internal/database"
 	common "scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
 	"scenic-spots-api/internal/models"
 	"scenic-spots-api/utils/generics"
 	"strconv"
+	"time"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func buildReviewQuery(collectionRef *firestore.CollectionRef, params models.ReviewQueryParams) (firestore.Query, error) {
-	var limit int
-	var err error
 	query := collectionRef.Query
 
 	query = query.Where("spotId", "==", params.SpotId)
 
 	if params.Limit != "" {
-		limit, err = strconv.Atoi(params.Limit)
-		if err != nil {
+		if _, err := strconv.Atoi(params.Limit); err != nil {
 			return firestore.Query{}, fmt.Errorf("invalid limit parameter")
 		}
-		query = query.Limit(limit)
 	}
 
 	if params.AddedBy != "" {
 		query = query.Where("addedBy", "==", params.AddedBy)
 	}
 
+	switch params.Sort {
+	case "":
+	case "helpful":
+		query = query.OrderBy("helpfulCount", firestore.Desc)
+	case "newest":
+		query = query.OrderBy("createdAt", firestore.Desc)
+	case "rating":
+		query = query.OrderBy("rating", firestore.Desc)
+	default:
+		return firestore.Query{}, fmt.Errorf("invalid sort parameter")
+	}
+
 	return query, nil
 }
 
-func GetReviews(ctx context.Context, params models.ReviewQueryParams) ([]models.Review, error) {
+func GetReviews(ctx context.Context, params models.ReviewQueryParams) (models.Page[models.Review], error) {
 	client := database.GetFirestoreClient()
 	collectionRef := client.Collection(models.ReviewCollectionName)
 
 	query, err := buildReviewQuery(collectionRef, params)
 	if err != nil {
-		return []models.Review{}, &apierrors.InvalidQueryParameterError{
+		return models.Page[models.Review]{}, &apierrors.InvalidQueryParameterError{
 			Message: err.Error(),
 		}
 	}
 
-	found, err := common.GetAllItems[*models.Review](ctx, query)
+	// limit is kept as an alias of pageSize for the older clients.
+	if params.PageSize == "" {
+		params.PageSize = params.Limit
+	}
+
+	page, err := common.GetPage[*models.Review](ctx, collectionRef, query, params.PageParams)
 	if err != nil {
-		return []models.Review{}, err
+		return models.Page[models.Review]{}, err
 	}
 
-	result := generics.DereferenceAll(found)
-	return result, nil
+	return models.Page[models.Review]{
+		Items:         generics.DereferenceAll(page.Items),
+		NextPageToken: page.NextPageToken,
+	}, nil
 }
 
 func AddReview(ctx context.Context, review models.Review) (models.Review, error) {
@@ -68,7 +88,46 @@ func DeleteAllReviews(ctx context.Context, spotId string) error {
 	client := database.GetFirestoreClient()
 	query := client.Collection(models.ReviewCollectionName).Where("spotId", "==", spotId)
 
-	return common.DeleteAllItems(ctx, query)
+	found, err := common.GetAllItems[*models.Review](ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// Deleted one by one, so that the subcollections of the reviews are removed as well.
+	for _, review := range found {
+		if err := DeleteReviewById(ctx, review.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the average rating and the number of reviews of the spot.
+func GetRatingSummary(ctx context.Context, spotId string) (float32, int, error) {
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.ReviewCollectionName).Where("spotId", "==", spotId)
+	result, err := query.NewAggregationQuery().
+		WithAvg("rating", "average").
+		WithCount("count").
+		Get(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	count, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid count aggregation result")
+	}
+	if count.GetIntegerValue() == 0 {
+		return 0, 0, nil
+	}
+
+	average, ok := result["average"].(*firestorepb.Value)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid average aggregation result")
+	}
+
+	return float32(average.GetDoubleValue()), int(count.GetIntegerValue()), nil
 }
 
 func FindReviewById(ctx context.Context, id string) (models.Review, error) {
@@ -80,15 +139,198 @@ func FindReviewById(ctx context.Context, id string) (models.Review, error) {
 	return *result, nil
 }
 
-func UpdateReviewById(ctx context.Context, id string, updatedReview models.ReviewInfo) error {
+// Stores the current version of the review in its history subcollection before overwriting it.
+func UpdateReviewById(ctx context.Context, id string, updatedReview models.ReviewInfo, editedBy string) error {
+	client := database.GetFirestoreClient()
+	reviewRef := client.Collection(models.ReviewCollectionName).Doc(id)
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(reviewRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return repoerrors.ErrDoesNotExist
+			}
+			return err
+		}
+
+		var previous models.Review
+		if err := doc.DataTo(&previous); err != nil {
+			return err
+		}
+
+		revision, err := generics.StructToMapLower(models.ReviewRevision{
+			Rating:   previous.Rating,
+			Content:  previous.Content,
+			EditedBy: editedBy,
+			EditedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(reviewRef.Collection(models.ReviewHistoryCollectionName).NewDoc(), revision); err != nil {
+			return err
+		}
+		return tx.Update(reviewRef, []firestore.Update{
+			{Path: "rating", Value: updatedReview.Rating},
+			{Path: "content", Value: updatedReview.Content},
+			{Path: "edited", Value: true},
+		})
+	})
+}
+
+func GetReviewHistory(ctx context.Context, id string) ([]models.ReviewRevision, error) {
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.ReviewCollectionName).Doc(id).Collection(models.ReviewHistoryCollectionName).
+		OrderBy("editedAt", firestore.Desc)
+
+	found, err := common.GetAllItems[*models.ReviewRevision](ctx, query)
+	if err != nil {
+		return []models.ReviewRevision{}, err
+	}
+
+	return generics.DereferenceAll(found), nil
+}
+
+func SetReply(ctx context.Context, reviewId string, reply models.ReviewReply) error {
+	data, err := generics.StructToMapLower(reply)
+	if err != nil {
+		return err
+	}
+
 	client := database.GetFirestoreClient()
-	_, err := client.Collection(models.ReviewCollectionName).Doc(id).Update(ctx, []firestore.Update{
-		{Path: "rating", Value: updatedReview.Rating},
-		{Path: "content", Value: updatedReview.Content},
+	_, err = client.Collection(models.ReviewCollectionName).Doc(reviewId).Update(ctx, []firestore.Update{
+		{Path: "reply", Value: data},
+	})
+	return err
+}
+
+func DeleteReply(ctx context.Context, reviewId string) error {
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.ReviewCollectionName).Doc(reviewId).Update(ctx, []firestore.Update{
+		{Path: "reply", Value: firestore.Delete},
 	})
 	return err
 }
 
 func DeleteReviewById(ctx context.Context, id string) error {
+	client := database.GetFirestoreClient()
+	reviewRef := client.Collection(models.ReviewCollectionName).Doc(id)
+	for _, subcollection := range []string{models.ReviewVoteCollectionName, models.ReviewHistoryCollectionName} {
+		if err := common.DeleteAllItems(ctx, reviewRef.Collection(subcollection).Query); err != nil {
+			return err
+		}
+	}
+
 	return common.DeleteItemById(ctx, models.ReviewCollectionName, id)
 }
+
+// Sets the users vote on the review and updates the counters in a single transaction.
+// Casting the same vote twice returns ErrAlreadyExists, casting the opposite one switches it.
+func VoteOnReview(ctx context.Context, reviewId string, userName string, helpful bool) error {
+	client := database.GetFirestoreClient()
+	reviewRef := client.Collection(models.ReviewCollectionName).Doc(reviewId)
+	voteRef := reviewRef.Collection(models.ReviewVoteCollectionName).Doc(userName)
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := tx.Get(reviewRef); err != nil {
+			if status.Code(err) == codes.NotFound {
+				return repoerrors.ErrDoesNotExist
+			}
+			return err
+		}
+
+		updates := []firestore.Update{{Path: voteCounterPath(helpful), Value: firestore.Increment(1)}}
+
+		voteDoc, err := tx.Get(voteRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var previous models.ReviewVote
+			if err := voteDoc.DataTo(&previous); err != nil {
+				return err
+			}
+			if previous.Helpful == helpful {
+				return repoerrors.ErrAlreadyExists
+			}
+			updates = append(updates, firestore.Update{Path: voteCounterPath(previous.Helpful), Value: firestore.Increment(-1)})
+		}
+
+		vote, err := generics.StructToMapLower(models.ReviewVote{
+			Helpful:   helpful,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(voteRef, vote); err != nil {
+			return err
+		}
+		return tx.Update(reviewRef, updates)
+	})
+}
+
+func DeleteVote(ctx context.Context, reviewId string, userName string) error {
+	client := database.GetFirestoreClient()
+	reviewRef := client.Collection(models.ReviewCollectionName).Doc(reviewId)
+	voteRef := reviewRef.Collection(models.ReviewVoteCollectionName).Doc(userName)
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		voteDoc, err := tx.Get(voteRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return repoerrors.ErrDoesNotExist
+			}
+			return err
+		}
+
+		var vote models.ReviewVote
+		if err := voteDoc.DataTo(&vote); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(voteRef); err != nil {
+			return err
+		}
+		return tx.Update(reviewRef, []firestore.Update{
+			{Path: voteCounterPath(vote.Helpful), Value: firestore.Increment(-1)},
+		})
+	})
+}
+
+// Sets the missing vote counters of reviews added before voting existed to zero, since sorting by
+// helpfulness leaves out the reviews without the field.
+func BackfillVoteCounts(ctx context.Context) (int, error) {
+	client := database.GetFirestoreClient()
+	docs, err := client.Collection(models.ReviewCollectionName).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, doc := range docs {
+		data := doc.Data()
+		var updates []firestore.Update
+		for _, path := range []string{voteCounterPath(true), voteCounterPath(false)} {
+			if _, ok := data[path]; !ok {
+				updates = append(updates, firestore.Update{Path: path, Value: 0})
+			}
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if _, err := doc.Ref.Update(ctx, updates); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+func voteCounterPath(helpful bool) string {
+	if helpful {
+		return "helpfulCount"
+	}
+	return "unhelpfulCount"
+}
@@ -2,22 +2,42 @@ package spot
 
 import (
 	"context"
+	"fmt"
 	"scenic-spots-api/internal/api/apierrors"
 	"scenic-spots-api/internal/database"
 	common "scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
 	"scenic-spots-api/internal/models"
 	"scenic-spots-api/utils/calc"
 	"scenic-spots-api/utils/generics"
+	"scenic-spots-api/utils/search"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 )
 
+// Firestore limit of values compared with the "in" operator.
+const maxCategories int = 30
+
+// Firestore limit of values compared with the "array-contains-any" operator.
+const maxSearchTokens int = 30
+
+const defaultSuggestions int = 5
+const maxSuggestions int = 10
+const maxSuggestionCandidates int = 50
+
+// Half of the latitude band searched for the nearby suggestions, about 55 km.
+const suggestionBandDegrees float64 = 0.5
+
 func buildSpotQuery(collectionRef *firestore.CollectionRef, params models.SpotQueryParams) (firestore.Query, error) {
 	query := collectionRef.Query
 
 	if params.Name != "" {
-		query = query.Where("name", "==", strings.ToLower(params.Name))
+		query = query.Where("normalizedName", "==", search.Normalize(params.Name))
 	}
 
 	if params.Latitude != "" {
@@ -32,36 +52,214 @@ func buildSpotQuery(collectionRef *firestore.CollectionRef, params models.SpotQu
 	}
 
 	if params.Category != "" {
-		query = query.Where("category", "==", strings.ToLower(params.Category))
+		categories := strings.Split(strings.ToLower(params.Category), ",")
+		if len(categories) > maxCategories {
+			return firestore.Query{}, fmt.Errorf("too many categories, at most %d are allowed", maxCategories)
+		}
+		if len(categories) == 1 {
+			query = query.Where("category", "==", categories[0])
+		} else {
+			query = query.Where("category", "in", categories)
+		}
 	}
 
 	if params.AddedBy != "" {
 		query = query.Where("addedBy", "==", params.AddedBy)
 	}
 
+	if params.MinRating != "" {
+		minRating, err := strconv.ParseFloat(params.MinRating, 32)
+		if err != nil || minRating < 0 || minRating > 5 {
+			return firestore.Query{}, fmt.Errorf("invalid minRating parameter")
+		}
+		query = query.Where("rating", ">=", minRating)
+	}
+
+	if params.HasPhotos != "" {
+		hasPhotos, err := strconv.ParseBool(params.HasPhotos)
+		if err != nil {
+			return firestore.Query{}, fmt.Errorf("invalid hasPhotos parameter")
+		}
+		if hasPhotos {
+			query = query.Where("photos", "!=", []string{})
+		} else {
+			query = query.Where("photos", "==", []string{})
+		}
+	}
+
+	query, err := addCreatedAtRange(query, params.CreatedAfter, params.CreatedBefore)
+	if err != nil {
+		return firestore.Query{}, err
+	}
+
+	return addSpotOrdering(query, params)
+}
+
+func addCreatedAtRange(query firestore.Query, createdAfter string, createdBefore string) (firestore.Query, error) {
+	var after, before time.Time
+	var err error
+
+	if createdAfter != "" {
+		after, err = time.Parse(time.RFC3339, createdAfter)
+		if err != nil {
+			return firestore.Query{}, fmt.Errorf("invalid createdAfter parameter, RFC 3339 timestamp expected")
+		}
+		query = query.Where("createdAt", ">", after)
+	}
+
+	if createdBefore != "" {
+		before, err = time.Parse(time.RFC3339, createdBefore)
+		if err != nil {
+			return firestore.Query{}, fmt.Errorf("invalid createdBefore parameter, RFC 3339 timestamp expected")
+		}
+		query = query.Where("createdAt", "<", before)
+	}
+
+	if createdAfter != "" && createdBefore != "" && !after.Before(before) {
+		return firestore.Query{}, fmt.Errorf("createdAfter must be earlier than createdBefore")
+	}
+
+	return query, nil
+}
+
+// Distance is not stored in the database, so spots sorted by distance are ordered after the query is run.
+func addSpotOrdering(query firestore.Query, params models.SpotQueryParams) (firestore.Query, error) {
+	direction := firestore.Asc
+	switch params.Order {
+	case "", "asc":
+	case "desc":
+		direction = firestore.Desc
+	default:
+		return firestore.Query{}, fmt.Errorf("invalid order parameter")
+	}
+
+	switch params.Sort {
+	case "":
+		if params.Order != "" {
+			return firestore.Query{}, fmt.Errorf("order parameter requires the sort parameter")
+		}
+	case "createdAt", "name", "rating":
+		query = query.OrderBy(params.Sort, direction)
+	case "distance":
+		if params.Latitude == "" {
+			return firestore.Query{}, fmt.Errorf("sort=distance requires latitude, longitude and radius parameters")
+		}
+		if params.PageToken != "" {
+			return firestore.Query{}, fmt.Errorf("sort=distance does not support the pageToken parameter")
+		}
+	default:
+		return firestore.Query{}, fmt.Errorf("invalid sort parameter")
+	}
+
 	return query, nil
 }
 
-func GetSpot(ctx context.Context, params models.SpotQueryParams) ([]models.Spot, error) {
+func GetSpot(ctx context.Context, params models.SpotQueryParams) (models.Page[models.Spot], error) {
 	client := database.GetFirestoreClient()
 	collectionRef := client.Collection(models.SpotCollectionName)
 
 	query, err := buildSpotQuery(collectionRef, params)
 	if err != nil {
-		return []models.Spot{}, &apierrors.InvalidQueryParameterError{
+		return models.Page[models.Spot]{}, &apierrors.InvalidQueryParameterError{
 			Message: err.Error(),
 		}
 	}
 
+	if params.Sort == "distance" {
+		return getSpotsByDistance(ctx, query, params)
+	}
+
+	page, err := common.GetPage[*models.Spot](ctx, collectionRef, query, params.PageParams)
+	if err != nil {
+		return models.Page[models.Spot]{}, err
+	}
+
+	return models.Page[models.Spot]{
+		Items:         generics.DereferenceAll(page.Items),
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+// Returns only the closest spots that fit on a single page, as the whole result set has to be sorted in memory.
+func getSpotsByDistance(ctx context.Context, query firestore.Query, params models.SpotQueryParams) (models.Page[models.Spot], error) {
+	pageSize, err := common.ParsePageSize(params.PageSize)
+	if err != nil {
+		return models.Page[models.Spot]{}, &apierrors.InvalidQueryParameterError{
+			Message: err.Error(),
+		}
+	}
+
+	// Parameters were already validated while building the query.
+	latitude, _ := strconv.ParseFloat(params.Latitude, 64)
+	longitude, _ := strconv.ParseFloat(params.Longitude, 64)
+	radius, _ := strconv.ParseFloat(params.Radius, 64)
+
 	found, err := common.GetAllItems[*models.Spot](ctx, query)
+	if err != nil {
+		return models.Page[models.Spot]{}, err
+	}
+
+	distances := make(map[string]float64, len(found))
+	spots := make([]models.Spot, 0, len(found))
+	for _, spot := range found {
+		distance := calc.DistanceKm(latitude, longitude, spot.Latitude, spot.Longitude)
+		if distance <= radius {
+			distances[spot.Id] = distance
+			spots = append(spots, *spot)
+		}
+	}
+
+	sort.SliceStable(spots, func(i, j int) bool {
+		if params.Order == "desc" {
+			return distances[spots[i].Id] > distances[spots[j].Id]
+		}
+		return distances[spots[i].Id] < distances[spots[j].Id]
+	})
+
+	if len(spots) > pageSize {
+		spots = spots[:pageSize]
+	}
+
+	return models.Page[models.Spot]{Items: spots}, nil
+}
+
+// Returns all of the spots in the radius, without pagination.
+func GetSpotsInRadius(ctx context.Context, latitude float64, longitude float64, radiusKm float64) ([]models.Spot, error) {
+	client := database.GetFirestoreClient()
+	query, err := buildSpotQuery(client.Collection(models.SpotCollectionName), models.SpotQueryParams{
+		Latitude:  strconv.FormatFloat(latitude, 'f', -1, 64),
+		Longitude: strconv.FormatFloat(longitude, 'f', -1, 64),
+		Radius:    strconv.FormatFloat(radiusKm, 'f', -1, 64),
+	})
 	if err != nil {
 		return []models.Spot{}, err
 	}
 
-	return generics.DereferenceAll(found), nil
+	found, err := common.GetAllItems[*models.Spot](ctx, query)
+	if err != nil {
+		return []models.Spot{}, err
+	}
+
+	spots := make([]models.Spot, 0, len(found))
+	for _, spot := range found {
+		if calc.DistanceKm(latitude, longitude, spot.Latitude, spot.Longitude) <= radiusKm {
+			spots = append(spots, *spot)
+		}
+	}
+	return spots, nil
+}
+
+func UpdateRating(ctx context.Context, id string, rating float32, reviewCount int) error {
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.SpotCollectionName).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "rating", Value: rating},
+		{Path: "reviewCount", Value: reviewCount},
+	})
+	return err
 }
 
 func AddSpot(ctx context.Context, spot models.Spot) (models.Spot, error) {
+	spot.IndexForSearch()
 	addedSpot, err := common.AddItem(ctx, models.SpotCollectionName, &spot)
 	if err != nil {
 		return models.Spot{}, err
@@ -80,17 +278,353 @@ func FindSpotById(ctx context.Context, id string) (models.Spot, error) {
 }
 
 func UpdateSpot(ctx context.Context, id string, updatedSpot models.NewSpot) error {
+	indexed := models.Spot{Name: updatedSpot.Name, Description: updatedSpot.Description}
+
 	client := database.GetFirestoreClient()
-	_, err := client.Collection(models.SpotCollectionName).Doc(id).Update(ctx, []firestore.Update{
+	_, err := client.Collection(models.SpotCollectionName).Doc(id).Update(ctx, append([]firestore.Update{
 		{Path: "name", Value: updatedSpot.Name},
 		{Path: "description", Value: updatedSpot.Description},
 		{Path: "latitude", Value: updatedSpot.Latitude},
 		{Path: "longitude", Value: updatedSpot.Longitude},
 		{Path: "category", Value: updatedSpot.Category},
+	}, searchIndexUpdates(indexed)...))
+	return err
+}
+
+func searchIndexUpdates(spot models.Spot) []firestore.Update {
+	spot.IndexForSearch()
+	return []firestore.Update{
+		{Path: "normalizedName", Value: spot.NormalizedName},
+		{Path: "searchTokens", Value: spot.SearchTokens},
+		{Path: "namePrefixes", Value: spot.NamePrefixes},
+	}
+}
+
+// Candidates are the spots sharing at least one word with the query, ranked by the number of matched words.
+// Words matched in the name weigh more than the ones matched in the description.
+func SearchSpots(ctx context.Context, params models.SpotSearchParams) (models.Page[models.Spot], error) {
+	tokens := search.Tokenize(params.Query)
+	if len(tokens) == 0 {
+		return models.Page[models.Spot]{}, &apierrors.InvalidQueryParameterError{
+			Message: "q parameter must contain at least one word",
+		}
+	}
+	if len(tokens) > maxSearchTokens {
+		return models.Page[models.Spot]{}, &apierrors.InvalidQueryParameterError{
+			Message: fmt.Sprintf("q parameter can contain at most %d words", maxSearchTokens),
+		}
+	}
+
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.SpotCollectionName).Where("searchTokens", "array-contains-any", tokens)
+
+	found, err := common.GetAllItems[*models.Spot](ctx, query)
+	if err != nil {
+		return models.Page[models.Spot]{}, err
+	}
+
+	normalizedQuery := search.Normalize(params.Query)
+	scores := make(map[string]int, len(found))
+	for _, spot := range found {
+		scores[spot.Id] = relevance(*spot, tokens, normalizedQuery)
+	}
+
+	spots := generics.DereferenceAll(found)
+	// Ties are ordered by the ID, so the following pages see the same order.
+	sort.Slice(spots, func(i, j int) bool {
+		if scores[spots[i].Id] != scores[spots[j].Id] {
+			return scores[spots[i].Id] > scores[spots[j].Id]
+		}
+		return spots[i].Id < spots[j].Id
+	})
+
+	return common.PageOffset(spots, params.PageParams)
+}
+
+func relevance(spot models.Spot, queryTokens []string, normalizedQuery string) int {
+	nameTokens := search.Tokenize(spot.Name)
+	descriptionTokens := search.Tokenize(spot.Description)
+
+	score := 0
+	for _, token := range queryTokens {
+		if slices.Contains(nameTokens, token) {
+			score += 3
+		} else if slices.Contains(descriptionTokens, token) {
+			score += 1
+		}
+	}
+
+	if spot.NormalizedName == normalizedQuery {
+		score += 10
+	} else if strings.Contains(spot.NormalizedName, normalizedQuery) {
+		score += 5
+	}
+	return score
+}
+
+// Suggests the spots with a word of the name starting with the prefix. Without a location the more reviewed
+// spots come first, with a location - the closer ones.
+func SuggestSpots(ctx context.Context, params models.SpotSuggestParams) ([]models.SpotSuggestion, error) {
+	prefix := search.Normalize(params.Prefix)
+	if prefix == "" {
+		return []models.SpotSuggestion{}, &apierrors.InvalidQueryParameterError{
+			Message: "prefix parameter is required",
+		}
+	}
+
+	limit := defaultSuggestions
+	if params.Limit != "" {
+		parsed, err := strconv.Atoi(params.Limit)
+		if err != nil || parsed < 1 || parsed > maxSuggestions {
+			return []models.SpotSuggestion{}, &apierrors.InvalidQueryParameterError{
+				Message: fmt.Sprintf("invalid limit parameter, expected a number between 1 and %d", maxSuggestions),
+			}
+		}
+		limit = parsed
+	}
+
+	var latitude, longitude float64
+	biased := params.Latitude != "" || params.Longitude != ""
+	if biased {
+		var latErr, lonErr error
+		latitude, latErr = strconv.ParseFloat(params.Latitude, 64)
+		longitude, lonErr = strconv.ParseFloat(params.Longitude, 64)
+		if latErr != nil || lonErr != nil {
+			return []models.SpotSuggestion{}, &apierrors.InvalidQueryParameterError{
+				Message: "invalid location, both latitude and longitude are required",
+			}
+		}
+	}
+
+	client := database.GetFirestoreClient()
+	candidates := client.Collection(models.SpotCollectionName).
+		Select("name", "category", "latitude", "longitude", "reviewCount", "normalizedName").
+		Where("namePrefixes", "array-contains", prefix)
+
+	// The candidates are limited before they are ranked, so the most reviewed ones are taken.
+	found, err := common.GetAllItems[*models.Spot](ctx, candidates.
+		OrderBy("reviewCount", firestore.Desc).
+		Limit(maxSuggestionCandidates))
+	if err != nil {
+		return []models.SpotSuggestion{}, err
+	}
+
+	// With a location, the spots around it are added, so the closest ones are not missed among the popular ones.
+	if biased {
+		nearby, err := common.GetAllItems[*models.Spot](ctx, candidates.
+			Where("latitude", ">=", latitude-suggestionBandDegrees).
+			Where("latitude", "<=", latitude+suggestionBandDegrees).
+			OrderBy("latitude", firestore.Asc).
+			Limit(maxSuggestionCandidates))
+		if err != nil {
+			return []models.SpotSuggestion{}, err
+		}
+		for _, spot := range nearby {
+			if !slices.ContainsFunc(found, func(other *models.Spot) bool { return other.Id == spot.Id }) {
+				found = append(found, spot)
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		iStarts := strings.HasPrefix(found[i].NormalizedName, prefix)
+		jStarts := strings.HasPrefix(found[j].NormalizedName, prefix)
+		if iStarts != jStarts {
+			return iStarts
+		}
+		if biased {
+			return calc.DistanceKm(latitude, longitude, found[i].Latitude, found[i].Longitude) <
+				calc.DistanceKm(latitude, longitude, found[j].Latitude, found[j].Longitude)
+		}
+		return found[i].ReviewCount > found[j].ReviewCount
+	})
+
+	suggestions := make([]models.SpotSuggestion, 0, limit)
+	for _, spot := range found {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, models.SpotSuggestion{
+			Id:       spot.Id,
+			Name:     spot.Name,
+			Category: spot.Category,
+		})
+	}
+
+	return suggestions, nil
+}
+
+// Rebuilds the search index of all of the spots, used for the spots added before the index existed.
+func ReindexSpots(ctx context.Context) error {
+	client := database.GetFirestoreClient()
+	found, err := common.GetAllItems[*models.Spot](ctx, client.Collection(models.SpotCollectionName).Query)
+	if err != nil {
+		return err
+	}
+
+	for _, spot := range found {
+		_, err := client.Collection(models.SpotCollectionName).Doc(spot.Id).Update(ctx, searchIndexUpdates(*spot))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Spots added before ratings were stored lack the rating fields and are left out when filtering or sorting by them.
+func GetSpotIdsWithoutRating(ctx context.Context) ([]string, error) {
+	client := database.GetFirestoreClient()
+	docs, err := client.Collection(models.SpotCollectionName).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for _, doc := range docs {
+		data := doc.Data()
+		_, hasRating := data["rating"]
+		_, hasReviewCount := data["reviewCount"]
+		if !hasRating || !hasReviewCount {
+			ids = append(ids, doc.Ref.ID)
+		}
+	}
+	return ids, nil
+}
+
+func AddPhotos(ctx context.Context, id string, photoUrls []string) error {
+	urls := make([]interface{}, 0, len(photoUrls))
+	for _, url := range photoUrls {
+		urls = append(urls, url)
+	}
+
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.SpotCollectionName).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "photos", Value: firestore.ArrayUnion(urls...)},
 	})
 	return err
 }
 
+// Moves the reviews and photos of the source spot to the target spot and replaces the source spot with
+// a redirect. Redirects pointing to the source spot are updated to the target. A transaction is limited to
+// 500 writes, so the reviews and redirects are moved in bulk first, and the transaction moves only the ones
+// added in the meantime. A failed merge can be retried, as the source spot is deleted last.
+func MergeSpots(ctx context.Context, sourceId string, targetId string, mergedBy string) error {
+	client := database.GetFirestoreClient()
+	sourceRef := client.Collection(models.SpotCollectionName).Doc(sourceId)
+	targetRef := client.Collection(models.SpotCollectionName).Doc(targetId)
+	reviewsQuery := client.Collection(models.ReviewCollectionName).Where("spotId", "==", sourceId)
+	redirectsQuery := client.Collection(models.SpotRedirectCollectionName).Where("targetId", "==", sourceId)
+
+	for _, id := range []string{sourceId, targetId} {
+		if _, err := FindSpotById(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := updateAll(ctx, reviewsQuery, "spotId", targetId); err != nil {
+		return err
+	}
+	if err := updateAll(ctx, redirectsQuery, "targetId", targetId); err != nil {
+		return err
+	}
+
+	return client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshots, err := tx.GetAll([]*firestore.DocumentRef{sourceRef, targetRef})
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			if !snapshot.Exists() {
+				return repoerrors.ErrDoesNotExist
+			}
+		}
+
+		var source models.Spot
+		if err := snapshots[0].DataTo(&source); err != nil {
+			return err
+		}
+
+		reviews, err := tx.Documents(reviewsQuery).GetAll()
+		if err != nil {
+			return err
+		}
+		redirects, err := tx.Documents(redirectsQuery).GetAll()
+		if err != nil {
+			return err
+		}
+
+		for _, review := range reviews {
+			if err := tx.Update(review.Ref, []firestore.Update{{Path: "spotId", Value: targetId}}); err != nil {
+				return err
+			}
+		}
+		for _, redirect := range redirects {
+			if err := tx.Update(redirect.Ref, []firestore.Update{{Path: "targetId", Value: targetId}}); err != nil {
+				return err
+			}
+		}
+
+		if len(source.Photos) > 0 {
+			photos := make([]interface{}, 0, len(source.Photos))
+			for _, photo := range source.Photos {
+				photos = append(photos, photo)
+			}
+			if err := tx.Update(targetRef, []firestore.Update{{Path: "photos", Value: firestore.ArrayUnion(photos...)}}); err != nil {
+				return err
+			}
+		}
+
+		redirect, err := generics.StructToMapLower(models.SpotRedirect{
+			TargetId: targetId,
+			MergedBy: mergedBy,
+			MergedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(client.Collection(models.SpotRedirectCollectionName).Doc(sourceId), redirect); err != nil {
+			return err
+		}
+
+		return tx.Delete(sourceRef)
+	})
+}
+
+// Sets the field of all the documents matching the query with a bulk writer, which has no limit of writes.
+func updateAll(ctx context.Context, query firestore.Query, path string, value interface{}) error {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	bulkWriter := database.GetFirestoreClient().BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+	for _, doc := range docs {
+		job, err := bulkWriter.Update(doc.Ref, []firestore.Update{{Path: path, Value: value}})
+		if err != nil {
+			bulkWriter.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bulkWriter.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FindRedirect(ctx context.Context, id string) (models.SpotRedirect, error) {
+	redirect, err := common.FindItemById[*models.SpotRedirect](ctx, models.SpotRedirectCollectionName, id)
+	if err != nil {
+		return models.SpotRedirect{}, err
+	}
+
+	return *redirect, nil
+}
+
 func DeleteSpotById(ctx context.Context, id string) error {
 	if _, err := common.FindItemById[*models.Spot](ctx, models.SpotCollectionName, id); err != nil {
 		return err
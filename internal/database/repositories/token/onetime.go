@@ -0,0 +1,74 @@
+package token
+
+import (
+	"context"
+	"scenic-spots-api/internal/database"
+	"scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/generics"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func AddOneTimeToken(ctx context.Context, hash string, oneTimeToken models.OneTimeToken) error {
+	data, err := generics.StructToMapLower(oneTimeToken)
+	if err != nil {
+		return err
+	}
+
+	client := database.GetFirestoreClient()
+	_, err = client.Collection(models.OneTimeTokenCollectionName).Doc(hash).Create(ctx, data)
+	return err
+}
+
+// Returns the token without using it up, with the same checks as ConsumeOneTimeToken.
+func FindOneTimeToken(ctx context.Context, hash string, purpose string) (models.OneTimeToken, error) {
+	found, err := common.FindItemById[*models.OneTimeToken](ctx, models.OneTimeTokenCollectionName, hash)
+	if err != nil {
+		return models.OneTimeToken{}, err
+	}
+
+	if found.Used || found.Purpose != purpose || time.Now().After(found.ExpiresAt) {
+		return models.OneTimeToken{}, repoerrors.ErrDoesNotExist
+	}
+	return *found, nil
+}
+
+// Marks the token as used in a transaction, so it can be consumed only once.
+// Returns ErrDoesNotExist for missing, used and expired tokens, as well as the ones issued for another purpose.
+func ConsumeOneTimeToken(ctx context.Context, hash string, purpose string) (models.OneTimeToken, error) {
+	client := database.GetFirestoreClient()
+	tokenRef := client.Collection(models.OneTimeTokenCollectionName).Doc(hash)
+
+	var consumed models.OneTimeToken
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(tokenRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return repoerrors.ErrDoesNotExist
+			}
+			return err
+		}
+
+		consumed = models.OneTimeToken{}
+		if err := doc.DataTo(&consumed); err != nil {
+			return err
+		}
+		consumed.SetId(doc.Ref.ID)
+
+		if consumed.Used || consumed.Purpose != purpose || time.Now().After(consumed.ExpiresAt) {
+			return repoerrors.ErrDoesNotExist
+		}
+
+		return tx.Update(tokenRef, []firestore.Update{{Path: "used", Value: true}})
+	})
+	if err != nil {
+		return models.OneTimeToken{}, err
+	}
+
+	return consumed, nil
+}
@@ -0,0 +1,77 @@
+package token
+
+import (
+	"context"
+	"scenic-spots-api/internal/database"
+	"scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/models"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func AddSession(ctx context.Context, session models.Session) (models.Session, error) {
+	addedSession, err := common.AddItem(ctx, models.SessionCollectionName, &session)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return *addedSession, nil
+}
+
+func FindSessionById(ctx context.Context, id string) (models.Session, error) {
+	session, err := common.FindItemById[*models.Session](ctx, models.SessionCollectionName, id)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return *session, nil
+}
+
+// Returns the active sessions of the user, the most recently used first.
+func GetUserSessions(ctx context.Context, userId string) ([]models.Session, error) {
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.SessionCollectionName).Where("userId", "==", userId).Where("revoked", "==", false)
+
+	found, err := common.GetAllItems[*models.Session](ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.Session, 0, len(found))
+	for _, session := range found {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// Records the refresh of the session. Sessions are missing for token families issued before they were introduced.
+func TouchSession(ctx context.Context, id string, client models.ClientInfo) error {
+	firestoreClient := database.GetFirestoreClient()
+	_, err := firestoreClient.Collection(models.SessionCollectionName).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "lastUsedAt", Value: time.Now()},
+		{Path: "ipAddress", Value: client.IpAddress},
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+func revokeSession(ctx context.Context, id string) error {
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.SessionCollectionName).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"scenic-spots-api/internal/database"
+	"scenic-spots-api/internal/database/repositories/common"
+	"scenic-spots-api/internal/database/repositories/repoerrors"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/generics"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func AddRefreshToken(ctx context.Context, hash string, refreshToken models.RefreshToken) error {
+	data, err := generics.StructToMapLower(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	client := database.GetFirestoreClient()
+	_, err = client.Collection(models.RefreshTokenCollectionName).Doc(hash).Create(ctx, data)
+	return err
+}
+
+// Marks the token as rotated and stores its replacement in a single transaction, so a token can be rotated only once.
+// Returns the token as it was before the rotation - the replacement is stored only if the token was not rotated,
+// revoked or expired, the caller decides how to handle the other cases.
+func RotateRefreshToken(ctx context.Context, hash string, newHash string, replacement func(models.RefreshToken) models.RefreshToken) (models.RefreshToken, error) {
+	client := database.GetFirestoreClient()
+	tokenRef := client.Collection(models.RefreshTokenCollectionName).Doc(hash)
+	newTokenRef := client.Collection(models.RefreshTokenCollectionName).Doc(newHash)
+
+	var current models.RefreshToken
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(tokenRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return repoerrors.ErrDoesNotExist
+			}
+			return err
+		}
+
+		current = models.RefreshToken{}
+		if err := doc.DataTo(&current); err != nil {
+			return err
+		}
+		current.SetId(doc.Ref.ID)
+
+		if current.Rotated || current.Revoked || time.Now().After(current.ExpiresAt) {
+			return nil
+		}
+
+		data, err := generics.StructToMapLower(replacement(current))
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(newTokenRef, data); err != nil {
+			return err
+		}
+		return tx.Update(tokenRef, []firestore.Update{{Path: "rotated", Value: true}})
+	})
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	return current, nil
+}
+
+// Ends the session of the token family and revokes all of its refresh tokens.
+func RevokeTokenFamily(ctx context.Context, familyId string) error {
+	if err := revokeSession(ctx, familyId); err != nil {
+		return err
+	}
+
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.RefreshTokenCollectionName).Where("familyId", "==", familyId)
+
+	found, err := common.GetAllItems[*models.RefreshToken](ctx, query)
+	if err != nil {
+		return err
+	}
+
+	for _, refreshToken := range found {
+		_, err := client.Collection(models.RefreshTokenCollectionName).Doc(refreshToken.Id).Update(ctx, []firestore.Update{
+			{Path: "revoked", Value: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Revokes every refresh token issued to the user, ending all of the user sessions.
+func RevokeUserTokens(ctx context.Context, userId string) error {
+	client := database.GetFirestoreClient()
+	query := client.Collection(models.RefreshTokenCollectionName).Where("userId", "==", userId).Where("revoked", "==", false)
+
+	found, err := common.GetAllItems[*models.RefreshToken](ctx, query)
+	if err != nil {
+		return err
+	}
+
+	for _, refreshToken := range found {
+		_, err := client.Collection(models.RefreshTokenCollectionName).Doc(refreshToken.Id).Update(ctx, []firestore.Update{
+			{Path: "revoked", Value: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sessions, err := GetUserSessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := revokeSession(ctx, session.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
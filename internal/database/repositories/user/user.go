@@ -6,6 +6,13 @@ import (
 	"scenic-spots-api/internal/database/repositories/common"
 	"scenic-spots-api/internal/database/repositories/repoerrors"
 	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/generics"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func AddUser(ctx context.Context, newUser models.User) (models.User, error) {
@@ -44,3 +51,168 @@ func GetUserByField(ctx context.Context, fieldName, value string) (*models.User,
 
 	return results[0], nil
 }
+
+// Searches by the exact email if the search contains "@", otherwise by the case-sensitive prefix of the name.
+func buildUserQuery(collectionRef *firestore.CollectionRef, params models.UserQueryParams) firestore.Query {
+	query := collectionRef.Query
+
+	if params.Role != "" {
+		query = query.Where("role", "==", params.Role)
+	}
+
+	switch {
+	case params.Search == "":
+	case strings.Contains(params.Search, "@"):
+		query = query.Where("email", "==", params.Search)
+	default:
+		query = query.Where("name", ">=", params.Search).
+			Where("name", "<", params.Search+"\uf8ff").
+			OrderBy("name", firestore.Asc)
+	}
+
+	return query
+}
+
+func GetUsers(ctx context.Context, params models.UserQueryParams) (models.Page[models.User], error) {
+	client := database.GetFirestoreClient()
+	collectionRef := client.Collection(models.UserAuthCollectionName)
+
+	page, err := common.GetPage[*models.User](ctx, collectionRef, buildUserQuery(collectionRef, params), params.PageParams)
+	if err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	return models.Page[models.User]{
+		Items:         generics.DereferenceAll(page.Items),
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+func SetRole(ctx context.Context, id string, role string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "role", Value: role},
+	})
+}
+
+func SetSuspension(ctx context.Context, id string, suspension models.UserSuspension) error {
+	data, err := generics.StructToMapLower(suspension)
+	if err != nil {
+		return err
+	}
+
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "suspension", Value: data},
+	})
+}
+
+func ClearSuspension(ctx context.Context, id string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "suspension", Value: firestore.Delete},
+	})
+}
+
+func IncrementTokenVersion(ctx context.Context, id string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "tokenVersion", Value: firestore.Increment(1)},
+	})
+}
+
+func UpdatePassword(ctx context.Context, id string, hashedPassword string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "password", Value: hashedPassword},
+	})
+}
+
+func SetEmailVerified(ctx context.Context, id string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "unverified", Value: false},
+	})
+}
+
+func SetPendingTotpSecret(ctx context.Context, id string, secret string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "totpPendingSecret", Value: secret},
+	})
+}
+
+// Activates the pending secret, the step of the confirming code is stored so the code cannot be used again.
+func EnableTotp(ctx context.Context, id string, secret string, usedStep int64, recoveryCodeHashes []string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "totpEnabled", Value: true},
+		{Path: "totpSecret", Value: secret},
+		{Path: "totpPendingSecret", Value: ""},
+		{Path: "totpLastUsedStep", Value: usedStep},
+		{Path: "recoveryCodes", Value: recoveryCodeHashes},
+	})
+}
+
+func DisableTotp(ctx context.Context, id string) error {
+	return updateUser(ctx, id, []firestore.Update{
+		{Path: "totpEnabled", Value: false},
+		{Path: "totpSecret", Value: ""},
+		{Path: "totpPendingSecret", Value: ""},
+		{Path: "totpLastUsedStep", Value: 0},
+		{Path: "recoveryCodes", Value: []string{}},
+	})
+}
+
+// Records the time step of the used code in a transaction, so concurrent logins cannot use the same code.
+// Returns false if a code of the same or a later step was used in the meantime.
+func UseTotpStep(ctx context.Context, id string, step int64) (bool, error) {
+	return updateUserInTransaction(ctx, id, func(user models.User) []firestore.Update {
+		if step <= user.TotpLastUsedStep {
+			return nil
+		}
+		return []firestore.Update{{Path: "totpLastUsedStep", Value: step}}
+	})
+}
+
+// Removes the recovery code in a transaction, so it can be used only once. Returns false if it was already used.
+func UseRecoveryCode(ctx context.Context, id string, recoveryCodeHash string) (bool, error) {
+	return updateUserInTransaction(ctx, id, func(user models.User) []firestore.Update {
+		if !slices.Contains(user.RecoveryCodes, recoveryCodeHash) {
+			return nil
+		}
+		return []firestore.Update{{Path: "recoveryCodes", Value: firestore.ArrayRemove(recoveryCodeHash)}}
+	})
+}
+
+// Applies the updates returned for the current state of the user, returns false if there were none.
+func updateUserInTransaction(ctx context.Context, id string, updatesFor func(user models.User) []firestore.Update) (bool, error) {
+	client := database.GetFirestoreClient()
+	userRef := client.Collection(models.UserAuthCollectionName).Doc(id)
+
+	updated := false
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		updated = false
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return repoerrors.ErrDoesNotExist
+			}
+			return err
+		}
+
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		updates := updatesFor(user)
+		if len(updates) == 0 {
+			return nil
+		}
+		updated = true
+		return tx.Update(userRef, updates)
+	})
+	return updated, err
+}
+
+func updateUser(ctx context.Context, id string, updates []firestore.Update) error {
+	client := database.GetFirestoreClient()
+	_, err := client.Collection(models.UserAuthCollectionName).Doc(id).Update(ctx, updates)
+	if status.Code(err) == codes.NotFound {
+		return repoerrors.ErrDoesNotExist
+	}
+	return err
+}
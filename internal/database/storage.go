@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"scenic-spots-api/utils/logger"
 
@@ -13,6 +14,7 @@ import (
 
 var bucketHandle *storage.BucketHandle
 var bucketName string
+var publicObjectURL func(objectName string) string
 
 func InitalizeStorageClient(ctx context.Context) error {
 	var err error
@@ -29,6 +31,7 @@ func InitalizeStorageClient(ctx context.Context) error {
 	}
 
 	setBucketName()
+	setPublicObjectURL(mode)
 	bucketHandle, err = connectFunc(ctx)
 	if err != nil {
 		return err
@@ -85,3 +88,22 @@ func setBucketName() {
 func GetStorageBucketHandle() *storage.BucketHandle {
 	return bucketHandle
 }
+
+// Objects are served from the public URL of the bucket, so the bucket has to allow public reads.
+// The emulator serves them through the Firebase download endpoint instead.
+func setPublicObjectURL(mode string) {
+	if mode == "emulator" {
+		host := os.Getenv("STORAGE_EMULATOR_HOST_CONFIG")
+		publicObjectURL = func(objectName string) string {
+			return fmt.Sprintf("http://%s/v0/b/%s/o/%s?alt=media", host, bucketName, url.PathEscape(objectName))
+		}
+		return
+	}
+	publicObjectURL = func(objectName string) string {
+		return fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucketName, objectName)
+	}
+}
+
+func GetPublicObjectURL(objectName string) string {
+	return publicObjectURL(objectName)
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Saves every email as an .eml file in the directory, which can be opened in any mail client. Used in development.
+// The emails contain one-time tokens, so the files are readable only by the owner.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(ctx context.Context, message Message) error {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	name := time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix) + ".eml"
+	data, err := buildMIMEMessage(m.From, message)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o600)
+}
@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The saved emails contain one-time tokens, so other users must not be able to read them.
+func TestFileMailerWritesPrivateFiles(t *testing.T) {
+	dir := t.TempDir()
+	mailer := FileMailer{Dir: dir, From: testFrom}
+
+	if err := mailer.Send(t.Context(), Message{To: "jan@example.com", Subject: "Login link", Text: "token"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", files, err)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("file mode = %o, want 600", mode)
+	}
+}
@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Email ready to be delivered. The HTML part is optional, the text one is always sent.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Backend delivering the emails, replaceable with SetMailer.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+var current struct {
+	sync.RWMutex
+	mailer Mailer
+}
+
+func init() {
+	current.mailer = LogMailer{}
+}
+
+func SetMailer(mailer Mailer) {
+	current.Lock()
+	defer current.Unlock()
+	current.mailer = mailer
+}
+
+// Delivers the message right away. API requests should use Enqueue instead, so a failed delivery gets retried.
+func Send(ctx context.Context, message Message) error {
+	current.RLock()
+	mailer := current.mailer
+	current.RUnlock()
+
+	return mailer.Send(ctx, message)
+}
+
+// Selects the backend set in MAILER_MODE - [log / file / smtp].
+func InitializeMailer() error {
+	from := os.Getenv("MAILER_FROM")
+	if from == "" {
+		from = "Scenic Spots <no-reply@scenic-spots.local>"
+	}
+
+	switch mode := os.Getenv("MAILER_MODE"); mode {
+	case "", "log":
+		SetMailer(LogMailer{})
+	case "file":
+		dir := os.Getenv("MAILER_FILE_DIR")
+		if dir == "" {
+			dir = "./mail"
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		SetMailer(FileMailer{Dir: dir, From: from})
+	case "smtp":
+		mailer := SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		if mailer.Host == "" || mailer.Port == "" {
+			return fmt.Errorf("SMTP_HOST and SMTP_PORT are required for the smtp mailer mode")
+		}
+		SetMailer(mailer)
+	default:
+		return fmt.Errorf("Unknown MAILER_MODE: %s", mode)
+	}
+	return nil
+}
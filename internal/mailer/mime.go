@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"time"
+)
+
+// Builds the raw email, with both text and HTML parts as multipart/alternative if the HTML one is set.
+func buildMIMEMessage(from string, message Message) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString("From: " + from + "\r\n")
+	buffer.WriteString("To: " + message.To + "\r\n")
+	buffer.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	buffer.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		writePart(&buffer, "text/plain", message.Text)
+		return buffer.Bytes(), nil
+	}
+
+	boundaryBytes := make([]byte, 12)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, err
+	}
+	boundary := "scenic-spots-" + hex.EncodeToString(boundaryBytes)
+
+	buffer.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	buffer.WriteString("--" + boundary + "\r\n")
+	writePart(&buffer, "text/plain", message.Text)
+	buffer.WriteString("\r\n--" + boundary + "\r\n")
+	writePart(&buffer, "text/html", message.HTML)
+	buffer.WriteString("\r\n--" + boundary + "--\r\n")
+	return buffer.Bytes(), nil
+}
+
+func writePart(buffer *bytes.Buffer, contentType string, content string) {
+	buffer.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	writer := quotedprintable.NewWriter(buffer)
+	writer.Write([]byte(strings.ReplaceAll(content, "\n", "\r\n")))
+	writer.Close()
+}
@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"testing"
+)
+
+const testFrom = "Scenic Spots <no-reply@scenic-spots.local>"
+
+func TestBuildMIMEMessageText(t *testing.T) {
+	message := Message{To: "jan@example.com", Subject: "Zażółć gęślą jaźń", Text: "Line one\nLine two"}
+
+	parsed := parseMessage(t, message)
+
+	if from := parsed.Header.Get("From"); from != testFrom {
+		t.Errorf("From = %q, want %q", from, testFrom)
+	}
+	if to := parsed.Header.Get("To"); to != message.To {
+		t.Errorf("To = %q, want %q", to, message.To)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != message.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, message.Subject)
+	}
+	if contentType := parsed.Header.Get("Content-Type"); contentType != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q, want text/plain", contentType)
+	}
+	if body := readQuotedPrintable(t, parsed.Body); body != "Line one\r\nLine two" {
+		t.Errorf("body = %q, want the text with CRLF line endings", body)
+	}
+}
+
+func TestBuildMIMEMessageAlternative(t *testing.T) {
+	message := Message{To: "jan@example.com", Subject: "Welcome", Text: "Hello", HTML: "<p>Witaj, świecie</p>"}
+
+	parsed := parseMessage(t, message)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", parsed.Header.Get("Content-Type"), err)
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	want := []struct {
+		contentType string
+		body        string
+	}{
+		{contentType: "text/plain; charset=UTF-8", body: message.Text},
+		{contentType: "text/html; charset=UTF-8", body: message.HTML},
+	}
+	for _, part := range want {
+		found, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("reading the %s part failed: %v", part.contentType, err)
+		}
+		if contentType := found.Header.Get("Content-Type"); contentType != part.contentType {
+			t.Errorf("part Content-Type = %q, want %q", contentType, part.contentType)
+		}
+		if body := readQuotedPrintable(t, found); body != part.body {
+			t.Errorf("part body = %q, want %q", body, part.body)
+		}
+	}
+	if _, err := reader.NextRawPart(); err != io.EOF {
+		t.Errorf("expected only the text and HTML parts, got %v", err)
+	}
+}
+
+func parseMessage(t *testing.T, message Message) *mail.Message {
+	t.Helper()
+	data, err := buildMIMEMessage(testFrom, message)
+	if err != nil {
+		t.Fatalf("buildMIMEMessage failed: %v", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parsing the built message failed: %v", err)
+	}
+	return parsed
+}
+
+func readQuotedPrintable(t *testing.T, reader io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(reader))
+	if err != nil {
+		t.Fatalf("decoding the quoted-printable body failed: %v", err)
+	}
+	return string(body)
+}
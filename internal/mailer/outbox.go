@@ -0,0 +1,114 @@
+package mailer
+
+import (
+	"context"
+	outboxRepo "scenic-spots-api/internal/database/repositories/outbox"
+	"scenic-spots-api/internal/models"
+	"scenic-spots-api/utils/logger"
+	"strconv"
+	"time"
+)
+
+const (
+	outboxPollPeriod = 30 * time.Second
+	outboxBatchSize  = 20
+	outboxLease      = 5 * time.Minute
+	maxSendAttempts  = 6
+)
+
+// Delay before the next attempt, indexed by the number of failed attempts.
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+var wakeUpOutbox = make(chan struct{}, 1)
+
+// Stores the message in the outbox, from which it is sent in the background.
+// The request only fails if the message cannot be stored, a failed delivery is retried later.
+func Enqueue(ctx context.Context, message Message) error {
+	now := time.Now()
+	_, err := outboxRepo.AddMessage(ctx, models.OutboxMessage{
+		To:            message.To,
+		Subject:       message.Subject,
+		Text:          message.Text,
+		HTML:          message.HTML,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case wakeUpOutbox <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Renders the template and stores the message in the outbox.
+func EnqueueTemplate(ctx context.Context, to string, templateName string, data any) error {
+	message, err := Compose(to, templateName, data)
+	if err != nil {
+		return err
+	}
+	return Enqueue(ctx, message)
+}
+
+// Sends the outbox messages in the background - periodically, and right after new ones are enqueued.
+func StartOutbox(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(outboxPollPeriod)
+		defer ticker.Stop()
+		for {
+			processOutbox(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wakeUpOutbox:
+			}
+		}
+	}()
+}
+
+func processOutbox(ctx context.Context) {
+	messages, err := outboxRepo.ClaimDueMessages(ctx, outboxBatchSize, outboxLease)
+	if err != nil {
+		logger.Error("Reading the mail outbox failed: " + err.Error())
+		return
+	}
+
+	for _, message := range messages {
+		attempts := message.Attempts + 1
+		sendErr := Send(ctx, Message{
+			To:      message.To,
+			Subject: message.Subject,
+			Text:    message.Text,
+			HTML:    message.HTML,
+		})
+
+		if sendErr == nil {
+			err = outboxRepo.MarkSent(ctx, message.Id, attempts)
+		} else {
+			status, nextAttemptAt := afterFailedAttempt(attempts, time.Now())
+			if status == models.OutboxStatusFailed {
+				logger.Error("Giving up sending email " + message.Id + " after " + strconv.Itoa(attempts) + " attempts: " + sendErr.Error())
+			} else {
+				logger.Error("Sending email " + message.Id + " failed, retrying later: " + sendErr.Error())
+			}
+			err = outboxRepo.MarkAttemptFailed(ctx, message.Id, attempts, nextAttemptAt, sendErr.Error(), status)
+		}
+		if err != nil {
+			logger.Error("Updating the mail outbox failed: " + err.Error())
+		}
+	}
+}
+
+// Status of the message after its failed attempt and when to try it again. The message is given up
+// after maxSendAttempts, the later attempts wait for the last of the retry delays.
+func afterFailedAttempt(attempts int, now time.Time) (string, time.Time) {
+	if attempts >= maxSendAttempts {
+		return models.OutboxStatusFailed, now
+	}
+	return models.OutboxStatusPending, now.Add(retryDelays[min(attempts, len(retryDelays))-1])
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"scenic-spots-api/internal/models"
+	"testing"
+	"time"
+)
+
+func TestAfterFailedAttempt(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		attempts      int
+		status        string
+		nextAttemptAt time.Time
+	}{
+		{attempts: 1, status: models.OutboxStatusPending, nextAttemptAt: now.Add(time.Minute)},
+		{attempts: 2, status: models.OutboxStatusPending, nextAttemptAt: now.Add(5 * time.Minute)},
+		{attempts: 3, status: models.OutboxStatusPending, nextAttemptAt: now.Add(30 * time.Minute)},
+		{attempts: 4, status: models.OutboxStatusPending, nextAttemptAt: now.Add(2 * time.Hour)},
+		{attempts: 5, status: models.OutboxStatusPending, nextAttemptAt: now.Add(6 * time.Hour)},
+		{attempts: maxSendAttempts, status: models.OutboxStatusFailed, nextAttemptAt: now},
+		{attempts: maxSendAttempts + 1, status: models.OutboxStatusFailed, nextAttemptAt: now},
+	}
+
+	for _, test := range tests {
+		status, nextAttemptAt := afterFailedAttempt(test.attempts, now)
+		if status != test.status {
+			t.Errorf("afterFailedAttempt(%d) status = %q, want %q", test.attempts, status, test.status)
+		}
+		if !nextAttemptAt.Equal(test.nextAttemptAt) {
+			t.Errorf("afterFailedAttempt(%d) next attempt = %v, want %v", test.attempts, nextAttemptAt, test.nextAttemptAt)
+		}
+	}
+}
+
+// More attempts than retry delays must keep waiting for the last delay instead of indexing past it.
+func TestAfterFailedAttemptBeyondRetryDelays(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	saved := retryDelays
+	retryDelays = []time.Duration{time.Minute, time.Hour}
+	defer func() { retryDelays = saved }()
+
+	for attempts := len(retryDelays); attempts < maxSendAttempts; attempts++ {
+		status, nextAttemptAt := afterFailedAttempt(attempts, now)
+		if status != models.OutboxStatusPending || !nextAttemptAt.Equal(now.Add(time.Hour)) {
+			t.Errorf("afterFailedAttempt(%d) = %q, %v, want %q, %v", attempts, status, nextAttemptAt, models.OutboxStatusPending, now.Add(time.Hour))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// Sends the emails through an SMTP server. Without the username the server is used without authentication,
+// e.g. a local SMTP catcher such as MailHog or Mailpit.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(ctx context.Context, message Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// The From header may contain a display name, the envelope needs the address only.
+	sender := m.From
+	if address, err := mail.ParseAddress(m.From); err == nil {
+		sender = address.Address
+	}
+
+	data, err := buildMIMEMessage(m.From, message)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, sender, []string{message.To}, data); err != nil {
+		return fmt.Errorf("Error sending email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// Every email has a text template defining the "subject" and "text" blocks,
+// and an HTML template defining the "content" block rendered inside the common layout.
+const (
+	VerificationTemplate     = "verification"
+	PasswordResetTemplate    = "password_reset"
+	MagicLoginTemplate       = "magic_login"
+	ModerationNoticeTemplate = "moderation_notice"
+	DigestTemplate           = "digest"
+)
+
+type VerificationData struct {
+	Name string
+	Link string
+}
+
+type PasswordResetData struct {
+	Name string
+	Link string
+}
+
+type MagicLoginData struct {
+	Name string
+	Link string
+}
+
+type ModerationNoticeData struct {
+	Name         string
+	Action       string // e.g. "removed", "edited"
+	ContentType  string // e.g. "spot", "review"
+	ContentTitle string
+	Reason       string
+}
+
+type DigestData struct {
+	Name   string
+	Period string // e.g. "this week"
+	Spots  []DigestSpot
+}
+
+type DigestSpot struct {
+	Name     string
+	Category string
+	Link     string
+}
+
+// Renders the template into a message for the recipient.
+func Compose(to string, templateName string, data any) (Message, error) {
+	textTemplates, err := textTemplate.ParseFS(templateFiles, "templates/"+templateName+".txt.tmpl")
+	if err != nil {
+		return Message{}, fmt.Errorf("Unknown email template %s: %w", templateName, err)
+	}
+	htmlTemplates, err := htmlTemplate.ParseFS(templateFiles, "templates/layout.html.tmpl", "templates/"+templateName+".html.tmpl")
+	if err != nil {
+		return Message{}, fmt.Errorf("Unknown email template %s: %w", templateName, err)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplates.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, "layout", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -4,3 +4,21 @@ type APIError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
+
+type DuplicateSpotAPIError struct {
+	Code         int      `json:"code"`
+	Message      string   `json:"message"`
+	CandidateIds []string `json:"candidateIds"`
+}
+
+type TwoFactorRequiredAPIError struct {
+	Code           int    `json:"code"`
+	Message        string `json:"message"`
+	ChallengeToken string `json:"challengeToken"`
+}
+
+type InvalidFieldsAPIError struct {
+	Code    int                 `json:"code"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields"`
+}
@@ -3,3 +3,13 @@ package models
 const SpotCollectionName string = "spots"
 const ReviewCollectionName string = "reviews"
 const UserAuthCollectionName string = "user_auth"
+const SpotRedirectCollectionName string = "spot_redirects"
+const RefreshTokenCollectionName string = "refresh_tokens"
+const SessionCollectionName string = "sessions"
+const OneTimeTokenCollectionName string = "one_time_tokens"
+const MailOutboxCollectionName string = "mail_outbox"
+const LoginAttemptsCollectionName string = "login_attempts"
+
+// Subcollections
+const ReviewVoteCollectionName string = "votes"
+const ReviewHistoryCollectionName string = "history"
@@ -2,4 +2,5 @@ package models
 
 type Identifiable interface {
 	SetId(id string)
+	GetId() string
 }
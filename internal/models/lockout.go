@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Failed login counter of an account or an IP address.
+type LoginAttempts struct {
+	Id            string    `json:"id"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt"`
+	LockedUntil   time.Time `json:"lockedUntil"`
+}
+
+func (a *LoginAttempts) SetId(id string) {
+	a.Id = id
+}
+
+func (a *LoginAttempts) GetId() string {
+	return a.Id
+}
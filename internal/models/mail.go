@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusFailed  = "failed"
+)
+
+// Email waiting in the outbox, sent in the background and retried until it is delivered or runs out of attempts.
+type OutboxMessage struct {
+	Id            string    `json:"id"`
+	To            string    `json:"to"`
+	Subject       string    `json:"subject"`
+	Text          string    `json:"text"`
+	HTML          string    `json:"html"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	LastError     string    `json:"lastError"`
+	CreatedAt     time.Time `json:"createdAt"`
+	SentAt        time.Time `json:"sentAt"`
+}
+
+func (m *OutboxMessage) SetId(id string) {
+	m.Id = id
+}
+
+func (m *OutboxMessage) GetId() string {
+	return m.Id
+}
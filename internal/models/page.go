@@ -0,0 +1,12 @@
+package models
+
+// Response envelope of the paginated listings.
+type Page[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+type PageParams struct {
+	PageSize  string
+	PageToken string
+}
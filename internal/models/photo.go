@@ -0,0 +1,15 @@
+package models
+
+// Raw photo received in a multipart request, before it is uploaded to the storage.
+type PhotoUpload struct {
+	ContentType string
+	Data        []byte
+}
+
+// Photo stored in the bucket, with the user that uploaded it and the review it is attached to, if any.
+type Photo struct {
+	Id         string
+	URL        string
+	UploadedBy string
+	ReviewId   string
+}
@@ -3,22 +3,33 @@ package models
 import "time"
 
 type Review struct {
-	Id        string    `json:"id"`
-	SpotId    string    `json:"spotId"`
-	Rating    float32   `json:"rating"`
-	Content   string    `json:"content"`
-	AddedBy   string    `json:"addedBy"`
-	CreatedAt time.Time `json:"createdAt"`
+	Id             string       `json:"id"`
+	SpotId         string       `json:"spotId"`
+	Rating         float32      `json:"rating"`
+	Content        string       `json:"content"`
+	AddedBy        string       `json:"addedBy"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	HelpfulCount   int          `json:"helpfulCount"`
+	UnhelpfulCount int          `json:"unhelpfulCount"`
+	Reply          *ReviewReply `json:"reply"`
+	Edited         bool         `json:"edited"`
+	Photos         []string     `json:"photos"`
 }
 
 func (r *Review) SetId(id string) {
 	r.Id = id
 }
 
+func (r *Review) GetId() string {
+	return r.Id
+}
+
 type NewReview struct {
-	SpotId  string  `json:"spotId" validate:"required"`
-	Rating  float32 `json:"rating" validate:"required,gte=0,lte=5"`
-	Content string  `json:"content" validate:"max=300"`
+	SpotId    string   `json:"spotId" validate:"required"`
+	Rating    float32  `json:"rating" validate:"required,gte=0,lte=5"`
+	Content   string   `json:"content" validate:"max=300"`
+	PhotoIds  []string `json:"photoIds" validate:"max=5,dive,required"`
+	AddToSpot bool     `json:"addToSpot"`
 }
 
 type ReviewInfo struct {
@@ -26,8 +37,58 @@ type ReviewInfo struct {
 	Content string  `json:"content" validate:"max=300"`
 }
 
+// Official response to the review, posted by the owner of the spot or a user allowed to reply on any spot.
+type ReviewReply struct {
+	Content   string    `json:"content"`
+	AddedBy   string    `json:"addedBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ReviewReplyInfo struct {
+	Content string `json:"content" validate:"required,max=300"`
+}
+
+// Previous version of the review, stored in the history subcollection on every update.
+type ReviewRevision struct {
+	Id       string    `json:"id"`
+	Rating   float32   `json:"rating"`
+	Content  string    `json:"content"`
+	EditedBy string    `json:"editedBy"`
+	EditedAt time.Time `json:"editedAt"`
+}
+
+func (r *ReviewRevision) SetId(id string) {
+	r.Id = id
+}
+
+func (r *ReviewRevision) GetId() string {
+	return r.Id
+}
+
 type ReviewQueryParams struct {
 	SpotId  string
 	Limit   string
 	AddedBy string
+	Sort    string
+	PageParams
+}
+
+// Stored in the votes subcollection of a review, document ID is the voting users name.
+type ReviewVote struct {
+	Id        string    `json:"id"`
+	Helpful   bool      `json:"helpful"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (v *ReviewVote) SetId(id string) {
+	v.Id = id
+}
+
+func (v *ReviewVote) GetId() string {
+	return v.Id
+}
+
+type ReviewVoteInfo struct {
+	Helpful *bool `json:"helpful" validate:"required"`
 }
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"scenic-spots-api/utils/search"
+	"time"
+)
 
 type Spot struct {
 	Id          string    `json:"id"`
@@ -12,25 +15,90 @@ type Spot struct {
 	Photos      []string  `json:"photos"`
 	AddedBy     string    `json:"addedBy"`
 	CreatedAt   time.Time `json:"createdAt"`
+	Rating      float32   `json:"rating"`
+	ReviewCount int       `json:"reviewCount"`
+
+	// Search index, kept in sync with the name and description on every write.
+	NormalizedName string   `json:"-"`
+	SearchTokens   []string `json:"-"`
+	NamePrefixes   []string `json:"-"`
 }
 
 func (s *Spot) SetId(id string) {
 	s.Id = id
 }
 
+func (s *Spot) GetId() string {
+	return s.Id
+}
+
 type NewSpot struct {
 	Name        string  `json:"name" validate:"required,max=32"`
 	Description string  `json:"description" validate:"max=300"`
 	Latitude    float64 `json:"latitude" validate:"required,gte=-90,lte=90"`
 	Longitude   float64 `json:"longitude" validate:"required,gte=-180,lte=180"`
 	Category    string  `json:"category" validate:"required,max=32"`
+
+	// Set by the user after a conflict, to confirm the spot is not a duplicate of the returned candidates.
+	ConfirmNotDuplicate bool `json:"confirmNotDuplicate"`
 }
 
 type SpotQueryParams struct {
-	Name      string
+	Name          string
+	Latitude      string
+	Longitude     string
+	Radius        string
+	Category      string // comma separated list of categories
+	AddedBy       string
+	MinRating     string
+	HasPhotos     string
+	CreatedAfter  string
+	CreatedBefore string
+	Sort          string
+	Order         string
+	PageParams
+}
+
+func (s *Spot) IndexForSearch() {
+	s.NormalizedName = search.Normalize(s.Name)
+	s.SearchTokens = search.Tokenize(s.Name + " " + s.Description)
+	s.NamePrefixes = search.Prefixes(s.Name)
+}
+
+type SpotSearchParams struct {
+	Query string
+	PageParams
+}
+
+type SpotSuggestParams struct {
+	Prefix    string
 	Latitude  string
 	Longitude string
-	Radius    string
-	Category  string
-	AddedBy   string
+	Limit     string
+}
+
+type SpotSuggestion struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type SpotMergeInfo struct {
+	TargetId string `json:"targetId" validate:"required"`
+}
+
+// Left in place of a spot merged into another one, document ID is the ID of the merged spot.
+type SpotRedirect struct {
+	Id       string    `json:"id"`
+	TargetId string    `json:"targetId"`
+	MergedBy string    `json:"mergedBy"`
+	MergedAt time.Time `json:"mergedAt"`
+}
+
+func (r *SpotRedirect) SetId(id string) {
+	r.Id = id
+}
+
+func (r *SpotRedirect) GetId() string {
+	return r.Id
 }
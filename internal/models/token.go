@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Refresh token stored in the database, document ID is the SHA-256 hash of the token.
+// Tokens issued by rotating one another share the same family, which is revoked as a whole on token reuse.
+type RefreshToken struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"userId"`
+	FamilyId  string    `json:"familyId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Rotated   bool      `json:"rotated"`
+	Revoked   bool      `json:"revoked"`
+}
+
+func (t *RefreshToken) SetId(id string) {
+	t.Id = id
+}
+
+func (t *RefreshToken) GetId() string {
+	return t.Id
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// Login session of the user, shared by all refresh tokens of one family - the document ID is the family ID.
+type Session struct {
+	Id          string    `json:"id"`
+	UserId      string    `json:"userId"`
+	DeviceLabel string    `json:"deviceLabel"`
+	IpAddress   string    `json:"ipAddress"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastUsedAt  time.Time `json:"lastUsedAt"`
+	Revoked     bool      `json:"revoked"`
+}
+
+func (s *Session) SetId(id string) {
+	s.Id = id
+}
+
+func (s *Session) GetId() string {
+	return s.Id
+}
+
+// Describes the client a session is started or refreshed from.
+type ClientInfo struct {
+	DeviceLabel string
+	IpAddress   string
+}
+
+const (
+	PasswordResetPurpose     = "password_reset"
+	EmailVerificationPurpose = "email_verification"
+	MagicLoginPurpose        = "magic_login"
+	TwoFactorLoginPurpose    = "two_factor_login"
+)
+
+// Single-use token sent to the user by email, document ID is the SHA-256 hash of the token.
+type OneTimeToken struct {
+	Id        string    `json:"id"`
+	UserId    string    `json:"userId"`
+	Purpose   string    `json:"purpose"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Used      bool      `json:"used"`
+}
+
+func (t *OneTimeToken) SetId(id string) {
+	t.Id = id
+}
+
+func (t *OneTimeToken) GetId() string {
+	return t.Id
+}
@@ -1,29 +1,137 @@
 package models
 
+import "time"
+
 type User struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Role     string `json:"role"`
+	// Incremented to invalidate every token issued to the user before.
+	TokenVersion int `json:"tokenVersion"`
+	// Set for accounts registered until the email gets verified, accounts without the field are verified.
+	Unverified bool `json:"unverified"`
+	// TOTP two-factor authentication - the pending secret is replaced by the active one once a code confirms it.
+	TotpEnabled       bool     `json:"totpEnabled"`
+	TotpSecret        string   `json:"totpSecret"`
+	TotpPendingSecret string   `json:"totpPendingSecret"`
+	TotpLastUsedStep  int64    `json:"totpLastUsedStep"`
+	RecoveryCodes     []string `json:"recoveryCodes"`
+	// Set while the user is suspended or banned, the expired ones are kept until the user is unsuspended.
+	Suspension *UserSuspension `json:"suspension"`
 }
 
 func (r *User) SetId(id string) {
 	r.Id = id
 }
 
+func (r *User) GetId() string {
+	return r.Id
+}
+
+type UserSuspension struct {
+	Reason      string    `json:"reason"`
+	SuspendedBy string    `json:"suspendedBy"`
+	SuspendedAt time.Time `json:"suspendedAt"`
+	// Nil for a ban, which lasts until the user is unsuspended.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+func (s *UserSuspension) IsActive(now time.Time) bool {
+	return s != nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+// User as seen by the admins, without the password and two-factor authentication secrets.
+type UserView struct {
+	Id               string          `json:"id"`
+	Name             string          `json:"name"`
+	Email            string          `json:"email"`
+	Role             string          `json:"role"`
+	EmailVerified    bool            `json:"emailVerified"`
+	TwoFactorEnabled bool            `json:"twoFactorEnabled"`
+	Suspended        bool            `json:"suspended"`
+	Suspension       *UserSuspension `json:"suspension"`
+}
+
+type UserQueryParams struct {
+	Search string
+	Role   string
+	PageParams
+}
+
+type UserRoleInfo struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type UserSuspensionInfo struct {
+	Reason string `json:"reason" validate:"required,max=300"`
+	// Leave empty to ban the user until unsuspended.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
 type UserRegisterInfo struct {
 	Name     string `json:"name" validate:"required,min=3,max=20"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UserCredentials struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required,min=6"`
+	DeviceLabel string `json:"deviceLabel" validate:"max=100"`
 }
 
 type UserTokenResponse struct {
-	Token   string `json:"token"`
-	LocalId string `json:"localId"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	LocalId      string `json:"localId"`
+}
+
+type PasswordChangeInfo struct {
+	OldPassword string `json:"oldPassword" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
+type PasswordForgotInfo struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetInfo struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"newPassword" validate:"required"`
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type MagicLinkLogin struct {
+	Token       string `json:"token" validate:"required"`
+	DeviceLabel string `json:"deviceLabel" validate:"max=100"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type TwoFactorCodeInfo struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorDisableInfo struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// Completes the login of a user with two-factor authentication, the code can also be one of the recovery codes.
+type TwoFactorLoginInfo struct {
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+	DeviceLabel    string `json:"deviceLabel" validate:"max=100"`
 }
@@ -2,12 +2,20 @@ package internal
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	hHandler "scenic-spots-api/internal/api/handlers/health"
+	jHandler "scenic-spots-api/internal/api/handlers/jwks"
 	sHandler "scenic-spots-api/internal/api/handlers/spot"
 	uHandler "scenic-spots-api/internal/api/handlers/user"
+	"scenic-spots-api/internal/api/helpers"
+	reviewService "scenic-spots-api/internal/api/service/review"
+	"scenic-spots-api/internal/auth"
 	"scenic-spots-api/internal/database"
+	reviewRepo "scenic-spots-api/internal/database/repositories/review"
+	spotRepo "scenic-spots-api/internal/database/repositories/spot"
+	"scenic-spots-api/internal/mailer"
 	"scenic-spots-api/utils/logger"
 
 	"github.com/joho/godotenv"
@@ -18,6 +26,26 @@ func Start(ctx context.Context) error {
 		logger.Error(err.Error())
 		return err
 	}
+	if err := mailer.InitializeMailer(); err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	if err := helpers.InitializeTrustedProxies(); err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	if err := auth.InitializeRolePermissions(); err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	if err := auth.InitializePasswordPolicy(); err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	if err := auth.InitializeSigningKeys(ctx); err != nil {
+		logger.Error(err.Error())
+		return err
+	}
 	if err := database.InitializeFirestoreClient(ctx); err != nil {
 		logger.Error(err.Error())
 		return err
@@ -26,6 +54,30 @@ func Start(ctx context.Context) error {
 		logger.Error(err.Error())
 		return err
 	}
+	mailer.StartOutbox(ctx)
+	if os.Getenv("DB_REINDEX_SEARCH") == "true" {
+		if err := spotRepo.ReindexSpots(ctx); err != nil {
+			logger.Error(err.Error())
+			return err
+		}
+		logger.Success("Rebuilt the spot search index")
+	}
+	if os.Getenv("DB_BACKFILL_REVIEW_VOTES") == "true" {
+		updated, err := reviewRepo.BackfillVoteCounts(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+			return err
+		}
+		logger.Success(fmt.Sprintf("Backfilled the vote counters of %d reviews", updated))
+	}
+	if os.Getenv("DB_BACKFILL_SPOT_RATINGS") == "true" {
+		updated, err := reviewService.BackfillSpotRatings(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+			return err
+		}
+		logger.Success(fmt.Sprintf("Backfilled the ratings of %d spots", updated))
+	}
 	initializeHandlers()
 	return startTheServer()
 }
@@ -39,7 +91,9 @@ func initializeHandlers() {
 	http.HandleFunc("/health", hHandler.Health)
 	http.HandleFunc("/spot", sHandler.Spot)
 	http.HandleFunc("/spot/", sHandler.SpotById)
+	http.HandleFunc("/user", uHandler.Users)
 	http.HandleFunc("/user/", uHandler.User)
+	http.HandleFunc("/.well-known/jwks.json", jHandler.Jwks)
 }
 
 func startTheServer() error {
@@ -61,7 +115,7 @@ func startTheServer() error {
 		})
 	}
 
-	handlerWithCORS := corsMiddleware(http.DefaultServeMux)
+	handlerWithCORS := corsMiddleware(helpers.Authenticate(http.DefaultServeMux))
 
 	err := http.ListenAndServe(":"+port, handlerWithCORS)
 	if err != nil {
@@ -1,4 +1,5 @@
 import os
+import glob
 import json
 import jwt
 from datetime import datetime
@@ -7,12 +8,19 @@ from dotenv import load_dotenv
 load_dotenv(dotenv_path="../../.env")
 
 PORT = os.getenv("PORT", "8080")
-JWT_SECRET = os.getenv("JWT_SECRET")
+JWT_KEYS_DIR = os.path.join("../..", os.getenv("JWT_KEYS_DIR", "./keys"))
 
-if not JWT_SECRET:
-    raise ValueError("No JWT_SECRET in .env")
+# The newest key is the one the API signs with - start the API once before, so the key gets created.
+# Key names start with the creation time, so the newest one sorts last.
+key_files = sorted(glob.glob(os.path.join(JWT_KEYS_DIR, "*.pem")), key=os.path.basename)
+if not key_files:
+    raise ValueError("No signing keys in " + JWT_KEYS_DIR + ", start the API first")
 
-def generate_token(user_id, username, role, secret):
+with open(key_files[-1], "rb") as file:
+    SIGNING_KEY = file.read()
+KEY_ID = os.path.splitext(os.path.basename(key_files[-1]))[0]
+
+def generate_token(user_id, username, role, key):
     exp = int(datetime(2050, 1, 1).timestamp())
     payload = {
         "lid": user_id,
@@ -20,12 +28,12 @@ def generate_token(user_id, username, role, secret):
         "rol": role,
         "exp": exp
     }
-    return jwt.encode(payload, secret, algorithm="HS256")
+    return jwt.encode(payload, key, algorithm="EdDSA", headers={"kid": KEY_ID})
 
-# Valid tokens
-user1_token = generate_token("1", "user1", "user", JWT_SECRET)
-user2_token = generate_token("2", "user2", "user", JWT_SECRET)
-admin_token = generate_token("3", "admin", "admin", JWT_SECRET)
+# Valid tokens - the user IDs must match the seeded users, as the API checks if the user still exists
+user1_token = generate_token("y9AHPDr0ywBovDlqfT7R", "user1", "user", SIGNING_KEY)
+user2_token = generate_token("QyjpJ8ukw1doWVyK31Zc", "user2", "user", SIGNING_KEY)
+admin_token = generate_token("7kRpK1TnlSgpfgiYlSh4", "admin", "admin", SIGNING_KEY)
 
 
 # Invalid tokens:
@@ -60,3 +60,14 @@ func CoordinatesAfterRadius(latitude string, longitude string, radius string) (C
 		MaxLon: maxLon,
 	}, nil
 }
+
+// Great-circle distance between two points, calculated with the haversine formula.
+func DistanceKm(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	dLat := (lat2 - lat1) * math.Pi / 180.0
+	dLon := (lon2 - lon1) * math.Pi / 180.0
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180.0)*math.Cos(lat2*math.Pi/180.0)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -16,6 +16,19 @@ func DereferenceAll[T any](in []*T) []T {
 	return out
 }
 
+// Keeps the first occurrence of every value, in the original order.
+func Unique[T comparable](in []T) []T {
+	seen := make(map[T]bool, len(in))
+	out := make([]T, 0, len(in))
+	for _, value := range in {
+		if !seen[value] {
+			seen[value] = true
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
 func StructToMapLower[T any](item T) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	structValue := reflect.ValueOf(item)
@@ -0,0 +1,92 @@
+package password
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/nbutton23/zxcvbn-go"
+	"github.com/nbutton23/zxcvbn-go/match"
+)
+
+// Estimates how many guesses an attacker needs to find the password with zxcvbn: the password is split into
+// the cheapest sequence of known patterns (common passwords, words and names, the user's own data, sequences,
+// repeats, keyboard walks and dates), and the characters not covered by any are brute-forced.
+type Strength struct {
+	// Score from 0 (too guessable) to 4 (very unguessable).
+	Score int
+	// Base 2 logarithm of the guesses.
+	Entropy float64
+	// Patterns found in the cheapest split, e.g. DictionaryPattern.
+	Patterns []string
+}
+
+const (
+	DictionaryPattern = "dictionary"
+	UserInputPattern  = "user_input"
+	SequencePattern   = "sequence"
+	RepeatPattern     = "repeat"
+	KeyboardPattern   = "keyboard"
+	DatePattern       = "date"
+)
+
+// Name of the zxcvbn dictionary holding the user inputs, leetspeak matches get a suffix appended.
+const userInputsDictionary = "user_inputs"
+
+const minUserWordLength = 3
+
+// Patterns of zxcvbn reported by their own name, the other ones are brute-forced characters.
+var zxcvbnPatterns = map[string]string{
+	"dictionary": DictionaryPattern,
+	"sequence":   SequencePattern,
+	"repeat":     RepeatPattern,
+	"spatial":    KeyboardPattern,
+	"date":       DatePattern,
+}
+
+// Estimates the strength of the password. The user inputs, e.g. the name and email, are treated as the most
+// common words, so passwords built from them score low.
+func Estimate(password string, userInputs ...string) Strength {
+	if password == "" {
+		return Strength{}
+	}
+
+	result := zxcvbn.PasswordStrength(password, userWords(userInputs))
+
+	var patterns []string
+	for _, found := range result.MatchSequence {
+		if pattern := patternOf(found); pattern != "" && !slices.Contains(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return Strength{Score: result.Score, Entropy: result.Entropy, Patterns: patterns}
+}
+
+func patternOf(found match.Match) string {
+	pattern := zxcvbnPatterns[found.Pattern]
+	if pattern == DictionaryPattern && strings.HasPrefix(found.DictionaryName, userInputsDictionary) {
+		return UserInputPattern
+	}
+	return pattern
+}
+
+// Words of the user inputs, e.g. "Jan Kowalski" and "jan.kowalski@example.com" give "jan" and "kowalski".
+func userWords(userInputs []string) []string {
+	var words []string
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if local, _, found := strings.Cut(input, "@"); found {
+			input = local
+		}
+		words = append(words, input)
+		for _, word := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(word)) >= minUserWordLength {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
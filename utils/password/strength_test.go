@@ -0,0 +1,49 @@
+package password
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		password   string
+		userInputs []string
+		score      int
+		// Patterns expected among the reported ones.
+		patterns []string
+	}{
+		{password: "", score: 0},
+		{password: "password", score: 0, patterns: []string{DictionaryPattern}},
+		{password: "P@ssw0rd", score: 0, patterns: []string{DictionaryPattern}},
+		{password: "dragon!!", score: 0, patterns: []string{DictionaryPattern}},
+		{password: "iloveyou", score: 0, patterns: []string{DictionaryPattern}},
+		{password: "123456789", score: 0, patterns: []string{SequencePattern}},
+		{password: "abcdefgh", score: 0, patterns: []string{SequencePattern}},
+		{password: "aaaaaaaaaa", score: 0, patterns: []string{RepeatPattern}},
+		{password: "zxcvfr", score: 0, patterns: []string{KeyboardPattern}},
+		{password: "kx9qz", score: 1},
+		{password: "kx9qzw", score: 2},
+		{password: "kx9qzw2m", score: 4},
+		{password: "Tr0ub4dor&3", score: 4},
+		{password: "correct horse battery staple", score: 4, patterns: []string{DictionaryPattern}},
+		{
+			password:   "kowalski",
+			userInputs: []string{"Jan Kowalski", "jan.kowalski@example.com"},
+			score:      0,
+			patterns:   []string{UserInputPattern},
+		},
+	}
+
+	for _, test := range tests {
+		strength := Estimate(test.password, test.userInputs...)
+		if strength.Score != test.score {
+			t.Errorf("Estimate(%q, %q) score = %d, want %d", test.password, test.userInputs, strength.Score, test.score)
+		}
+		for _, pattern := range test.patterns {
+			if !slices.Contains(strength.Patterns, pattern) {
+				t.Errorf("Estimate(%q, %q) patterns = %v, want %s among them", test.password, test.userInputs, strength.Patterns, pattern)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Letters that do not decompose into a base letter and a combining mark.
+var letterReplacer = strings.NewReplacer(
+	"ł", "l",
+	"đ", "d",
+	"ø", "o",
+	"ß", "ss",
+	"æ", "ae",
+	"œ", "oe",
+)
+
+const minTokenLength = 2
+const maxPrefixLength = 20
+
+// Lowercases the text and strips the diacritics, e.g. "Książ Castle" becomes "ksiaz castle".
+func Normalize(text string) string {
+	stripMarks := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(stripMarks, strings.ToLower(text))
+	if err != nil {
+		stripped = strings.ToLower(text)
+	}
+	return letterReplacer.Replace(strings.TrimSpace(stripped))
+}
+
+// Splits the normalized text into unique words, skipping the ones shorter than two characters.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(Normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(word) < minTokenLength || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// Returns the prefixes of the whole normalized text and of each of its words, used for autocompletion.
+func Prefixes(text string) []string {
+	normalized := Normalize(text)
+	words := append([]string{normalized}, strings.Fields(normalized)...)
+
+	seen := make(map[string]bool)
+	prefixes := make([]string, 0)
+	for _, word := range words {
+		runes := []rune(word)
+		for length := 1; length <= len(runes) && length <= maxPrefixLength; length++ {
+			prefix := string(runes[:length])
+			if seen[prefix] {
+				continue
+			}
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// Trigram similarity of the normalized texts, from 0 (nothing in common) to 1 (equal).
+func Similarity(a string, b string) float64 {
+	aTrigrams := trigrams(Normalize(a))
+	bTrigrams := trigrams(Normalize(b))
+	if len(aTrigrams) == 0 || len(bTrigrams) == 0 {
+		return 0
+	}
+
+	common := 0
+	for trigram := range aTrigrams {
+		if bTrigrams[trigram] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(aTrigrams)+len(bTrigrams)-common)
+}
+
+// Words are padded with spaces, so that short words and word boundaries produce trigrams as well.
+func trigrams(text string) map[string]bool {
+	result := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			result[string(runes[i:i+3])] = true
+		}
+	}
+	return result
+}
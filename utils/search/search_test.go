@@ -0,0 +1,34 @@
+package search
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a          string
+		b          string
+		similarity float64
+	}{
+		{a: "", b: "", similarity: 0},
+		{a: "Lake", b: "", similarity: 0},
+		{a: "Lake", b: "Mountain", similarity: 0},
+		{a: "Lake Bled", b: "lake bled", similarity: 1},
+		{a: "Lake Bled", b: "Bled Lake", similarity: 1},
+		{a: "Łąka", b: "laka", similarity: 1},
+		{a: "Lake Bled", b: "Lake Bled!", similarity: 0.75},
+		{a: "Lake Bled", b: "Lake Bohinj", similarity: 0.375},
+		{a: "Eiffel Tower", b: "Eifel Tower", similarity: 11.0 / 14},
+	}
+
+	for _, test := range tests {
+		similarity := Similarity(test.a, test.b)
+		if math.Abs(similarity-test.similarity) > 1e-9 {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", test.a, test.b, similarity, test.similarity)
+		}
+		if reversed := Similarity(test.b, test.a); reversed != similarity {
+			t.Errorf("Similarity(%q, %q) = %v, not symmetric with %v", test.b, test.a, reversed, similarity)
+		}
+	}
+}